@@ -0,0 +1,76 @@
+package elevation
+
+import "math"
+
+// An affine is an affine transformation that maps (x, y) to (a*x + b*y + c,
+// d*x + e*y + f). GeoTIFFTiles use affines to map between pixel and model
+// coordinates.
+type affine struct {
+	a, b, c float64
+	d, e, f float64
+}
+
+// newAffineFromModelTransformation returns the affine encoded in a
+// ModelTransformationTag, which is a 4x4 matrix in row-major order. Only the
+// terms that affect raster space x and y are used.
+func newAffineFromModelTransformation(m []float64) affine {
+	return affine{
+		a: m[0], b: m[1], c: m[3],
+		d: m[4], e: m[5], f: m[7],
+	}
+}
+
+// newAffineFromTiepoint returns the affine for a ModelPixelScaleTag and a
+// single ModelTiepointTag. The model y axis points in the opposite direction to
+// the raster y axis.
+func newAffineFromTiepoint(scaleX, scaleY, i, j, x, y float64) affine {
+	return affine{
+		a: scaleX, b: 0, c: x - i*scaleX,
+		d: 0, e: -scaleY, f: y + j*scaleY,
+	}
+}
+
+// apply returns t applied to x, y.
+func (t affine) apply(x, y float64) (float64, float64) {
+	return t.a*x + t.b*y + t.c, t.d*x + t.e*y + t.f
+}
+
+// translate returns t preceded by a translation of dx, dy in the source
+// coordinate system.
+func (t affine) translate(dx, dy float64) affine {
+	t.c += t.a*dx + t.b*dy
+	t.f += t.d*dx + t.e*dy
+	return t
+}
+
+// scale returns t preceded by a scaling of sx, sy in the source coordinate
+// system.
+func (t affine) scale(sx, sy float64) affine {
+	t.a *= sx
+	t.d *= sx
+	t.b *= sy
+	t.e *= sy
+	return t
+}
+
+// inverse returns the inverse of t and whether t is invertible.
+func (t affine) inverse() (affine, bool) {
+	det := t.a*t.e - t.b*t.d
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return affine{}, false
+	}
+	return affine{
+		a: t.e / det,
+		b: -t.b / det,
+		c: (t.b*t.f - t.e*t.c) / det,
+		d: -t.d / det,
+		e: t.a / det,
+		f: (t.d*t.c - t.a*t.f) / det,
+	}, true
+}
+
+// isNorthUp returns whether t has no rotation or shear terms, a positive x
+// scale, and a negative y scale.
+func (t affine) isNorthUp() bool {
+	return t.b == 0 && t.d == 0 && t.a > 0 && t.e < 0
+}
@@ -0,0 +1,48 @@
+package elevation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAffineInverse(t *testing.T) {
+	sin, cos := math.Sincos(math.Pi / 6)
+	for _, tc := range []struct {
+		name      string
+		transform affine
+	}{
+		{
+			name:      "tiepoint",
+			transform: newAffineFromTiepoint(10, 10, 0, 0, 1000, 2000),
+		},
+		{
+			name:      "arc_second",
+			transform: newAffineFromTiepoint(1.0/3600, 1.0/3600, 0.5, 0.5, 6, 47),
+		},
+		{
+			name: "rotated",
+			transform: affine{
+				a: 2 * cos, b: -2 * sin, c: 1000,
+				d: -2 * sin, e: -2 * cos, f: 2000,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inverse, ok := tc.transform.inverse()
+			assert.True(t, ok)
+			for _, xy := range [][2]float64{{0, 0}, {0.5, 0.5}, {127, 3}, {-5, 1000}} {
+				modelX, modelY := tc.transform.apply(xy[0], xy[1])
+				x, y := inverse.apply(modelX, modelY)
+				assert.True(t, math.Abs(x-xy[0]) < 1e-6)
+				assert.True(t, math.Abs(y-xy[1]) < 1e-6)
+			}
+		})
+	}
+}
+
+func TestAffineInverse_Singular(t *testing.T) {
+	_, ok := affine{a: 1, b: 2, d: 2, e: 4}.inverse()
+	assert.False(t, ok)
+}
@@ -0,0 +1,264 @@
+package elevation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Overview resampling methods.
+const (
+	ResamplingAverage = iota
+	ResamplingNearest
+)
+
+// cogGhostAreaPrefix is the start of the GDAL ghost area that describes the
+// layout of a COG.
+const cogGhostAreaPrefix = "GDAL_STRUCTURAL_METADATA_SIZE="
+
+// cogMinOverviewSize is the size above which COGs should have overviews.
+const cogMinOverviewSize = 512
+
+// ErrInvalidCOG is returned when a file is not a valid Cloud Optimized
+// GeoTIFF.
+var ErrInvalidCOG = errors.New("invalid COG")
+
+// WithWriterResampling sets the resampling method used to generate COG
+// overviews, either [ResamplingAverage] (the default) or [ResamplingNearest].
+func WithWriterResampling(resampling int) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.resampling = resampling
+	}
+}
+
+// WriteCOG writes the samples of raster within bounds to w as a Cloud
+// Optimized GeoTIFF, as [WriteGeoTIFF] does, with internal overviews. Each
+// overview halves the size of the previous image, until the image fits in a
+// single tile. The IFDs come first, followed by the tile data of the smallest
+// overview through to the full resolution image, as described by a GDAL ghost
+// area. The full resolution samples are held in memory while the overviews
+// are generated.
+func WriteCOG(ctx context.Context, w io.Writer, raster Raster, bounds Bounds, options ...GeoTIFFWriterOption) error {
+	gw, err := newGeoTIFFWriter(raster, options...)
+	if err != nil {
+		return err
+	}
+	if gw.resampling != ResamplingAverage && gw.resampling != ResamplingNearest {
+		return fmt.Errorf("resampling %d: %w", gw.resampling, errors.ErrUnsupported)
+	}
+	grid, err := newWriterGrid(raster, bounds)
+	if err != nil {
+		return err
+	}
+
+	width, length := grid.width, grid.length
+	samples := make([]float32, width*length)
+	fields, err := gw.fields(width, length, grid)
+	if err != nil {
+		return err
+	}
+	tiles, err := gw.encodeTiles(ctx, width, length, func(x0, y0, x1, y1 int) ([]float32, error) {
+		tileSamples, err := grid.samples(ctx, raster, x0, y0, x1, y1)
+		if err != nil {
+			return nil, err
+		}
+		for y := y0; y < y1; y++ {
+			copy(samples[y*width+x0:y*width+x1], tileSamples[(y-y0)*(x1-x0):])
+		}
+		return tileSamples, nil
+	})
+	if err != nil {
+		return err
+	}
+	images := []*tiffImage{{fields: fields, tiles: tiles}}
+
+	for width > gw.tileSize || length > gw.tileSize {
+		samples, width, length = downsample(samples, width, length, gw.resampling)
+		fields, err := gw.fields(width, length, nil)
+		if err != nil {
+			return err
+		}
+		tiles, err := gw.encodeTiles(ctx, width, length, func(x0, y0, x1, y1 int) ([]float32, error) {
+			tileSamples := make([]float32, 0, (x1-x0)*(y1-y0))
+			for y := y0; y < y1; y++ {
+				tileSamples = append(tileSamples, samples[y*width+x0:y*width+x1]...)
+			}
+			return tileSamples, nil
+		})
+		if err != nil {
+			return err
+		}
+		images = append(images, &tiffImage{fields: fields, tiles: tiles})
+	}
+
+	return gw.encode(w, images, cogGhostArea())
+}
+
+// cogGhostArea returns the GDAL ghost area written by WriteCOG. Like GDAL, it
+// is padded with a space to an even length so that the IFD that follows it is
+// word-aligned.
+func cogGhostArea() []byte {
+	structuralMetadata := "" +
+		"LAYOUT=IFDS_BEFORE_DATA\n" +
+		"BLOCK_ORDER=ROW_MAJOR\n" +
+		"KNOWN_INCOMPATIBLE_EDITION=NO\n"
+	if (len(cogGhostAreaPrefix)+len("000000 bytes\n")+len(structuralMetadata))%2 != 0 {
+		structuralMetadata += " "
+	}
+	return fmt.Appendf(nil, "%s%06d bytes\n%s", cogGhostAreaPrefix, len(structuralMetadata), structuralMetadata)
+}
+
+// downsample returns samples, an image of width by length pixels, reduced by a
+// factor of two, and its width and length. With ResamplingAverage each pixel
+// is the average of the non-NaN samples in the corresponding 2x2 block, with
+// ResamplingNearest it is the top left sample of the block.
+func downsample(samples []float32, width, length, resampling int) ([]float32, int, int) {
+	newWidth, newLength := (width+1)/2, (length+1)/2
+	newSamples := make([]float32, newWidth*newLength)
+	for y := range newLength {
+		for x := range newWidth {
+			if resampling == ResamplingNearest {
+				newSamples[y*newWidth+x] = samples[2*y*width+2*x]
+				continue
+			}
+			var sum float64
+			var count int
+			for sy := 2 * y; sy < min(2*y+2, length); sy++ {
+				for sx := 2 * x; sx < min(2*x+2, width); sx++ {
+					if sample := samples[sy*width+sx]; !math.IsNaN(float64(sample)) {
+						sum += float64(sample)
+						count++
+					}
+				}
+			}
+			if count == 0 {
+				newSamples[y*newWidth+x] = float32(math.NaN())
+			} else {
+				newSamples[y*newWidth+x] = float32(sum / float64(count))
+			}
+		}
+	}
+	return newSamples, newWidth, newLength
+}
+
+// ValidateCOG checks whether f is a valid Cloud Optimized GeoTIFF, following
+// GDAL's validate_cloud_optimized_geotiff.py. It returns warnings about
+// recommended but optional features and an error wrapping [ErrInvalidCOG] for
+// each requirement that f does not meet.
+func (f *GeoTIFFTile) ValidateCOG() ([]string, error) {
+	if !f.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer f.file.release()
+
+	var warnings []string
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrInvalidCOG))
+	}
+
+	if f.imageWidth > cogMinOverviewSize || f.imageLength > cogMinOverviewSize {
+		if f.rowsPerStrip != 0 {
+			invalid("%dx%d image is not tiled", f.imageWidth, f.imageLength)
+		}
+		if len(f.overviews) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%dx%d image has no overviews", f.imageWidth, f.imageLength))
+		}
+	}
+	for i, overview := range f.overviews {
+		if overview.rowsPerStrip != 0 {
+			invalid("overview %d is not tiled", i)
+		}
+	}
+
+	// The main IFD must immediately follow the header and any ghost area.
+	layout, ghostAreaSize, err := f.cogGhostArea()
+	if err != nil {
+		return nil, err
+	}
+	if expected := f.headerSize + ghostAreaSize; f.ifdOffset != expected {
+		invalid("main IFD at offset %d, expected %d", f.ifdOffset, expected)
+	}
+
+	// Overview IFDs must follow the main IFD, in order of decreasing size.
+	// The tile data of each image must be in increasing order and after the
+	// tile data of all smaller overviews.
+	images := append([]*GeoTIFFTile{f}, f.overviews...)
+	maxIFDOffset := f.ifdOffset
+	for i, overview := range f.overviews {
+		if overview.ifdOffset <= images[i].ifdOffset {
+			invalid("overview %d IFD at offset %d, before the previous IFD at offset %d", i, overview.ifdOffset, images[i].ifdOffset)
+		}
+		maxIFDOffset = max(maxIFDOffset, overview.ifdOffset)
+	}
+	firstChunkOffsets := make([]uint64, len(images))
+	for i, image := range images {
+		name := "main image"
+		if i > 0 {
+			name = "overview " + strconv.Itoa(i-1)
+		}
+		var previousChunkOffset uint64
+		for j, chunkOffset := range image.chunkOffsets {
+			if chunkOffset == 0 {
+				continue
+			}
+			if chunkOffset < previousChunkOffset {
+				invalid("%s block %d at offset %d, before the previous block at offset %d", name, j, chunkOffset, previousChunkOffset)
+				break
+			}
+			if previousChunkOffset == 0 {
+				firstChunkOffsets[i] = chunkOffset
+			}
+			previousChunkOffset = chunkOffset
+		}
+		if firstChunkOffsets[i] != 0 && firstChunkOffsets[i] < image.ifdOffset {
+			invalid("%s data at offset %d, before its IFD at offset %d", name, firstChunkOffsets[i], image.ifdOffset)
+		}
+		if layout == "IFDS_BEFORE_DATA" && firstChunkOffsets[i] != 0 && firstChunkOffsets[i] < maxIFDOffset {
+			invalid("%s data at offset %d, before the last IFD at offset %d", name, firstChunkOffsets[i], maxIFDOffset)
+		}
+	}
+	for i := range len(images) - 1 {
+		if firstChunkOffsets[i] != 0 && firstChunkOffsets[i+1] != 0 && firstChunkOffsets[i] < firstChunkOffsets[i+1] {
+			invalid("overview %d data at offset %d, after the data of the larger image at offset %d", i, firstChunkOffsets[i+1], firstChunkOffsets[i])
+		}
+	}
+
+	return warnings, errors.Join(errs...)
+}
+
+// cogGhostArea returns the layout described by f's GDAL ghost area and the
+// size of the ghost area. If f has no ghost area then it returns an empty
+// layout and a size of zero.
+func (f *GeoTIFFTile) cogGhostArea() (string, uint64, error) {
+	header := make([]byte, len(cogGhostAreaPrefix)+len("000000 bytes\n"))
+	if err := f.readAt(context.Background(), header, f.headerSize); err != nil || !bytes.HasPrefix(header, []byte(cogGhostAreaPrefix)) {
+		return "", 0, nil
+	}
+	sizeStr, ok := strings.CutSuffix(string(header[len(cogGhostAreaPrefix):]), " bytes\n")
+	if !ok {
+		return "", 0, fmt.Errorf("ghost area: invalid header %q: %w", header, ErrInvalidCOG)
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size < 0 || size > 1<<16 {
+		return "", 0, fmt.Errorf("ghost area: invalid size %q: %w", sizeStr, ErrInvalidCOG)
+	}
+	structuralMetadata := make([]byte, size)
+	if err := f.readAt(context.Background(), structuralMetadata, f.headerSize+uint64(len(header))); err != nil {
+		return "", 0, fmt.Errorf("ghost area: %w", err)
+	}
+	var layout string
+	for line := range strings.SplitSeq(string(structuralMetadata), "\n") {
+		if value, ok := strings.CutPrefix(line, "LAYOUT="); ok {
+			layout = value
+		}
+	}
+	ghostAreaSize := uint64(len(header) + size)
+	return layout, ghostAreaSize + ghostAreaSize%2, nil
+}
@@ -0,0 +1,181 @@
+package elevation
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWriteCOG(t *testing.T) {
+	// Samples are NaN in the top left corner.
+	raster := &testFuncRaster{
+		scaleX: 10,
+		scaleY: 10,
+		srid:   3035,
+		sample: func(coord Coord) float64 {
+			x, y := coord.X/10, coord.Y/10
+			if x < 3 && y > 508 {
+				return math.NaN()
+			}
+			return float64(x%97 + 3*y%89)
+		},
+	}
+	bounds := Bounds{Max: Coord{X: 6400, Y: 5120}}
+
+	for _, tc := range []struct {
+		name       string
+		bigTIFF    bool
+		resampling int
+	}{
+		{
+			name:       "average",
+			resampling: ResamplingAverage,
+		},
+		{
+			name:       "nearest_bigtiff",
+			bigTIFF:    true,
+			resampling: ResamplingNearest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			assert.NoError(t, WriteCOG(t.Context(), &buffer, raster, bounds,
+				WithWriterBigTIFF(tc.bigTIFF),
+				WithWriterResampling(tc.resampling),
+				WithWriterTileSize(128),
+			))
+			data := buffer.Bytes()
+
+			geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			assert.NoError(t, err)
+			layout, _, err := geoTIFFTile.cogGhostArea()
+			assert.NoError(t, err)
+			assert.Equal(t, "IFDS_BEFORE_DATA", layout)
+			warnings, err := geoTIFFTile.ValidateCOG()
+			assert.NoError(t, err)
+			assert.Zero(t, warnings)
+
+			// Each overview halves the size of the previous image until it
+			// fits in a single tile.
+			width, length := geoTIFFTile.Size()
+			assert.Equal(t, 640, width)
+			assert.Equal(t, 512, length)
+			overviews := geoTIFFTile.Overviews()
+			assert.Equal(t, 3, len(overviews))
+			sizes := make([][2]int, len(overviews))
+			for i, overview := range overviews {
+				sizes[i][0], sizes[i][1] = overview.Size()
+			}
+			assert.Equal(t, [][2]int{{320, 256}, {160, 128}, {80, 64}}, sizes)
+
+			expected := make([]float32, width*length)
+			for y := range length {
+				for x := range width {
+					expected[y*width+x] = float32(raster.sample(Coord{X: 10*x + 5, Y: 5120 - 10*y - 5}))
+				}
+			}
+			for i, overview := range overviews {
+				scale := 10 << (i + 1)
+				expected, width, length = downsample(expected, width, length, tc.resampling)
+				coords := make([]Coord, 0, width*length)
+				for y := range length {
+					for x := range width {
+						coords = append(coords, Coord{X: scale*x + scale/2, Y: 5120 - scale*y - scale/2})
+					}
+				}
+				actual, err := overview.Samples(t.Context(), coords)
+				assert.NoError(t, err)
+				for j, sample := range actual {
+					assert.Equal(t, float64(expected[j]), sample, "overview %d sample %d", i, j)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCOG_Errors(t *testing.T) {
+	raster := &testFuncRaster{
+		scaleX: 10,
+		scaleY: 10,
+		sample: func(Coord) float64 {
+			return 0
+		},
+	}
+	var buffer bytes.Buffer
+	err := WriteCOG(t.Context(), &buffer, raster, Bounds{Max: Coord{X: 100, Y: 100}}, WithWriterResampling(-1))
+	assert.IsError(t, err, errors.ErrUnsupported)
+}
+
+func TestDownsample(t *testing.T) {
+	nan := float32(math.NaN())
+	samples := []float32{
+		1, 2, 3, 4, 5,
+		5, 6, nan, nan, 7,
+		9, 10, nan, nan, 8,
+	}
+
+	averageSamples, width, length := downsample(samples, 5, 3, ResamplingAverage)
+	assert.Equal(t, 3, width)
+	assert.Equal(t, 2, length)
+	assert.Equal(t, []float32{3.5, 3.5, 6, 9.5, nan, 8}, averageSamples)
+
+	nearestSamples, width, length := downsample(samples, 5, 3, ResamplingNearest)
+	assert.Equal(t, 3, width)
+	assert.Equal(t, 2, length)
+	assert.Equal(t, []float32{1, 3, 5, 9, nan, 8}, nearestSamples)
+}
+
+func TestGeoTIFFTile_ValidateCOG(t *testing.T) {
+	raster := &testFuncRaster{
+		scaleX: 10,
+		scaleY: 10,
+		srid:   3035,
+		sample: func(coord Coord) float64 {
+			return float64(coord.X + coord.Y)
+		},
+	}
+	bounds := Bounds{Max: Coord{X: 6000, Y: 1000}}
+
+	t.Run("no_overviews", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.NoError(t, WriteGeoTIFF(t.Context(), &buffer, raster, bounds))
+		data := buffer.Bytes()
+		geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+		warnings, err := geoTIFFTile.ValidateCOG()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"600x100 image has no overviews"}, warnings)
+	})
+
+	t.Run("small", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.NoError(t, WriteGeoTIFF(t.Context(), &buffer, raster, Bounds{Max: Coord{X: 1000, Y: 1000}}))
+		data := buffer.Bytes()
+		geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+		warnings, err := geoTIFFTile.ValidateCOG()
+		assert.NoError(t, err)
+		assert.Zero(t, warnings)
+	})
+
+	t.Run("data_before_ifd", func(t *testing.T) {
+		// encodeTestTIFF writes the tile data before the IFDs.
+		geoTIFFTile := newTestGeoTIFFTile(t, newTestGeoTIFF())
+		_, err := geoTIFFTile.ValidateCOG()
+		assert.IsError(t, err, ErrInvalidCOG)
+	})
+
+	t.Run("ghost_area", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.NoError(t, WriteCOG(t.Context(), &buffer, raster, bounds))
+		data := bytes.Clone(buffer.Bytes())
+		copy(data[8+len(cogGhostAreaPrefix):], "00000x")
+		geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+		_, err = geoTIFFTile.ValidateCOG()
+		assert.IsError(t, err, ErrInvalidCOG)
+	})
+}
@@ -0,0 +1,179 @@
+package elevation
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/image/tiff/lzw"
+)
+
+// TIFF compression schemes.
+const (
+	CompressionNone         = 1
+	CompressionLZW          = 5
+	CompressionAdobeDeflate = 8
+	CompressionPackBits     = 32773
+	CompressionDeflate      = 32946
+	CompressionLERC         = 34887
+	CompressionZSTD         = 50000
+)
+
+// A DecompressFunc decompresses src into dst. It returns an error if src does
+// not decompress to at least len(dst) bytes.
+type DecompressFunc func(dst, src []byte) error
+
+// defaultDecompressFuncs contains the built-in DecompressFuncs, keyed by TIFF
+// compression scheme.
+var defaultDecompressFuncs = map[int]DecompressFunc{
+	CompressionNone:         decompressNone,
+	CompressionLZW:          decompressLZW,
+	CompressionAdobeDeflate: decompressDeflate,
+	CompressionPackBits:     decompressPackBits,
+	CompressionDeflate:      decompressDeflate,
+	CompressionLERC:         decompressLERC,
+	CompressionZSTD:         decompressZSTD,
+}
+
+// A compressFunc returns src compressed.
+type compressFunc func(src []byte) ([]byte, error)
+
+// defaultCompressFuncs contains the built-in compressFuncs, keyed by TIFF
+// compression scheme.
+var defaultCompressFuncs = map[int]compressFunc{
+	CompressionNone:         compressNone,
+	CompressionAdobeDeflate: compressDeflate,
+	CompressionDeflate:      compressDeflate,
+	CompressionZSTD:         compressZSTD,
+}
+
+// getZSTDDecoder returns a shared ZSTD decoder. The decoder is only used for
+// stateless DecodeAll calls, which are safe for concurrent use.
+var getZSTDDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+})
+
+// getZSTDEncoder returns a shared ZSTD encoder. The encoder is only used for
+// stateless EncodeAll calls, which are safe for concurrent use.
+var getZSTDEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+})
+
+// compressNone returns a copy of src.
+func compressNone(src []byte) ([]byte, error) {
+	return bytes.Clone(src), nil
+}
+
+// compressDeflate returns src compressed with zlib, as used by both TIFF
+// DEFLATE compression schemes.
+func compressDeflate(src []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := zlib.NewWriter(&buffer)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// compressZSTD returns src compressed with ZSTD.
+func compressZSTD(src []byte) ([]byte, error) {
+	zstdEncoder, err := getZSTDEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return zstdEncoder.EncodeAll(src, nil), nil
+}
+
+// decompressNone copies uncompressed data from src into dst.
+func decompressNone(dst, src []byte) error {
+	if len(src) < len(dst) {
+		return io.ErrUnexpectedEOF
+	}
+	copy(dst, src)
+	return nil
+}
+
+// decompressDeflate decompresses DEFLATE-compressed data from src into dst.
+// TIFF files use the same zlib format for both the Adobe and legacy DEFLATE
+// compression schemes.
+func decompressDeflate(dst, src []byte) error {
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.ReadFull(r, dst)
+	return err
+}
+
+// decompressLZW decompresses TIFF LZW-compressed data from src into dst.
+func decompressLZW(dst, src []byte) error {
+	r := lzw.NewReader(bytes.NewReader(src), lzw.MSB, 8)
+	defer r.Close()
+	_, err := io.ReadFull(r, dst)
+	return err
+}
+
+// decompressZSTD decompresses ZSTD-compressed data from src into dst.
+func decompressZSTD(dst, src []byte) error {
+	zstdDecoder, err := getZSTDDecoder()
+	if err != nil {
+		return err
+	}
+	// Limit the capacity of dst so that DecodeAll allocates a new slice,
+	// rather than writing past the end of dst, if the decompressed data is
+	// too long.
+	data, err := zstdDecoder.DecodeAll(src, dst[:0:len(dst)])
+	switch {
+	case err != nil:
+		return err
+	case len(data) < len(dst):
+		return io.ErrUnexpectedEOF
+	case len(data) > len(dst):
+		return errors.New("decompressed ZSTD data too long")
+	case len(dst) > 0 && &data[0] != &dst[0]:
+		return errors.New("ZSTD data not decompressed into dst")
+	}
+	return nil
+}
+
+// decompressPackBits decompresses PackBits-compressed data from src into dst.
+// PackBits data is a sequence of runs, each starting with a header byte n
+// interpreted as a signed integer. If n is non-negative then the next n+1
+// bytes are copied literally. If n is between -127 and -1 then the next byte is
+// repeated 1-n times. If n is -128 then it is ignored.
+func decompressPackBits(dst, src []byte) error {
+	for len(dst) > 0 {
+		if len(src) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		n := int(int8(src[0]))
+		src = src[1:]
+		switch {
+		case n >= 0:
+			count := n + 1
+			if len(src) < count {
+				return io.ErrUnexpectedEOF
+			}
+			dst = dst[copy(dst, src[:count]):]
+			src = src[count:]
+		case n > -128:
+			if len(src) == 0 {
+				return io.ErrUnexpectedEOF
+			}
+			count := min(1-n, len(dst))
+			for i := range count {
+				dst[i] = src[0]
+			}
+			dst = dst[count:]
+			src = src[1:]
+		}
+	}
+	return nil
+}
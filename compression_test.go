@@ -0,0 +1,121 @@
+package elevation
+
+import (
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDecompressPackBits(t *testing.T) {
+	// Example from the TIFF 6.0 specification.
+	src := []byte{
+		0xfe, 0xaa, 0x02, 0x80, 0x00, 0x2a, 0xfd, 0xaa, 0x03, 0x80, 0x00, 0x2a, 0x22, 0xf7, 0xaa,
+	}
+	expected := []byte{
+		0xaa, 0xaa, 0xaa, 0x80, 0x00, 0x2a, 0xaa, 0xaa, 0xaa, 0xaa, 0x80, 0x00, 0x2a, 0x22,
+		0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa,
+	}
+	dst := make([]byte, len(expected))
+	assert.NoError(t, decompressPackBits(dst, src))
+	assert.Equal(t, expected, dst)
+
+	// A no-op header is ignored.
+	dst = make([]byte, 2)
+	assert.NoError(t, decompressPackBits(dst, []byte{0x80, 0xff, 0x01}))
+	assert.Equal(t, []byte{0x01, 0x01}, dst)
+
+	// Truncated data is an error.
+	dst = make([]byte, len(expected)+1)
+	assert.IsError(t, decompressPackBits(dst, src), io.ErrUnexpectedEOF)
+	assert.IsError(t, decompressPackBits(make([]byte, 4), []byte{0x03, 0x01}), io.ErrUnexpectedEOF)
+	assert.IsError(t, decompressPackBits(make([]byte, 4), []byte{0xfd}), io.ErrUnexpectedEOF)
+}
+
+func TestDecompressPackBits_RoundTrip(t *testing.T) {
+	for _, src := range [][]byte{
+		{},
+		{1},
+		{1, 1},
+		{1, 2, 3, 3, 3, 3, 4},
+		make([]byte, 1000),
+		func() []byte {
+			data := make([]byte, 1000)
+			for i := range data {
+				data[i] = byte(i * i / 7)
+			}
+			return data
+		}(),
+	} {
+		dst := make([]byte, len(src))
+		assert.NoError(t, decompressPackBits(dst, compressPackBits(src)))
+		assert.Equal(t, src, dst)
+	}
+}
+
+func TestDecompressNone(t *testing.T) {
+	dst := make([]byte, 3)
+	assert.NoError(t, decompressNone(dst, []byte{1, 2, 3, 4}))
+	assert.Equal(t, []byte{1, 2, 3}, dst)
+	assert.IsError(t, decompressNone(dst, []byte{1, 2}), io.ErrUnexpectedEOF)
+}
+
+func TestDecompressZSTD(t *testing.T) {
+	src := make([]byte, 1000)
+	for i := range src {
+		src[i] = byte(i * i / 7)
+	}
+	dst := make([]byte, len(src))
+	assert.NoError(t, decompressZSTD(dst, mustCompress(compressZSTD)(src)))
+	assert.Equal(t, src, dst)
+
+	// Truncated data is an error.
+	dst = make([]byte, len(src)+1)
+	assert.IsError(t, decompressZSTD(dst, mustCompress(compressZSTD)(src)), io.ErrUnexpectedEOF)
+	assert.Error(t, decompressZSTD(dst, []byte{1, 2, 3}))
+
+	// Data longer than dst is an error and is not written past its end.
+	buffer := make([]byte, len(src))
+	dst = buffer[:len(src)-1]
+	assert.Error(t, decompressZSTD(dst, mustCompress(compressZSTD)(src)))
+	assert.Equal(t, byte(0), buffer[len(src)-1])
+}
+
+// compressPackBits returns data compressed with PackBits.
+func compressPackBits(data []byte) []byte {
+	var result []byte
+	for len(data) > 0 {
+		// Count the number of repeated bytes at the start of data.
+		run := 1
+		for run < len(data) && run < 128 && data[run] == data[0] {
+			run++
+		}
+		if run > 1 {
+			result = append(result, byte(1-run), data[0])
+			data = data[run:]
+			continue
+		}
+
+		// Otherwise, copy bytes literally until the next repeat.
+		literal := 1
+		for literal < len(data) && literal < 128 && (literal+1 >= len(data) || data[literal] != data[literal+1]) {
+			literal++
+		}
+		result = append(result, byte(literal-1))
+		result = append(result, data[:literal]...)
+		data = data[literal:]
+	}
+	return result
+}
+
+// mustCompress returns a function that compresses data with compressFunc,
+// panicking on any error.
+func mustCompress(compressFunc compressFunc) func([]byte) []byte {
+	return func(data []byte) []byte {
+		compressedData, err := compressFunc(data)
+		if err != nil {
+			panic(err)
+		}
+		return compressedData
+	}
+}
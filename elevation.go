@@ -1,6 +1,13 @@
 package elevation
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoScale is returned when a raster's scale is not positive, for example
+// when its coordinates are in degrees.
+var ErrNoScale = errors.New("no scale")
 
 // A Coord is a coordinate.
 type Coord struct {
@@ -8,13 +15,31 @@ type Coord struct {
 	Y int
 }
 
+// A Float64Coord is a floating point coordinate.
+type Float64Coord struct {
+	X float64
+	Y float64
+}
+
 // A TileCoord is a tile coordinate.
 type TileCoord struct {
 	C int // Column.
 	R int // Row.
 }
 
+// A Bounds is a rectangle of coordinates.
+type Bounds struct {
+	Min Coord
+	Max Coord
+}
+
 type Raster interface {
 	Samples(ctx context.Context, coords []Coord) ([]float64, error)
 	Scale() (int, int)
 }
+
+// A Float64Raster is a raster that can be sampled at floating point
+// coordinates.
+type Float64Raster interface {
+	SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error)
+}
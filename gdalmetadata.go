@@ -0,0 +1,108 @@
+package elevation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A GDALMetadata is the metadata that GDAL stores in the GDAL_METADATA TIFF
+// tag.
+type GDALMetadata struct {
+	Dataset map[string]string  // Dataset items in the default domain, keyed by name.
+	Bands   []GDALBandMetadata // Band metadata, indexed by band.
+	Items   []GDALMetadataItem // All items, in the order that they appear.
+}
+
+// A GDALBandMetadata is the metadata of a single band.
+type GDALBandMetadata struct {
+	Scale       float64           // Scale applied to samples, 1 if not set.
+	Offset      float64           // Offset added to scaled samples, 0 if not set.
+	Description string            // Band description.
+	UnitType    string            // Unit of samples, for example "m" or "ft".
+	Items       map[string]string // Other band items in the default domain, keyed by name.
+}
+
+// A GDALMetadataItem is a single item of GDAL metadata.
+type GDALMetadataItem struct {
+	Name   string `xml:"name,attr"`
+	Domain string `xml:"domain,attr"`
+	Role   string `xml:"role,attr"`
+	Sample *int   `xml:"sample,attr"` // Band, or nil for dataset items.
+	Value  string `xml:",chardata"`
+}
+
+// ParseGDALMetadata parses the XML value of a GDAL_METADATA TIFF tag of an
+// image with bands bands. Band items for other bands are ignored.
+func ParseGDALMetadata(data string, bands int) (*GDALMetadata, error) {
+	var gdalMetadataXML struct {
+		XMLName xml.Name           `xml:"GDALMetadata"`
+		Items   []GDALMetadataItem `xml:"Item"`
+	}
+	if err := xml.Unmarshal([]byte(strings.TrimRight(data, "\x00")), &gdalMetadataXML); err != nil {
+		return nil, fmt.Errorf("GDAL_METADATA: %w", err)
+	}
+
+	gdalMetadata := &GDALMetadata{
+		Dataset: make(map[string]string),
+		Bands:   make([]GDALBandMetadata, bands),
+		Items:   gdalMetadataXML.Items,
+	}
+	for i := range gdalMetadata.Bands {
+		gdalMetadata.Bands[i] = GDALBandMetadata{
+			Scale: 1,
+			Items: make(map[string]string),
+		}
+	}
+	for _, item := range gdalMetadata.Items {
+		if item.Domain != "" {
+			continue
+		}
+		if item.Sample == nil {
+			gdalMetadata.Dataset[item.Name] = item.Value
+			continue
+		}
+
+		band := *item.Sample
+		if band < 0 || bands <= band {
+			continue
+		}
+		bandMetadata := &gdalMetadata.Bands[band]
+
+		// GDAL identifies special items by their role. Older versions of GDAL
+		// only set their name.
+		role := item.Role
+		if role == "" {
+			role = strings.ToLower(item.Name)
+		}
+		switch role {
+		case "scale", "offset":
+			value, err := strconv.ParseFloat(strings.TrimSpace(item.Value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("GDAL_METADATA: %s: %w", item.Name, err)
+			}
+			if role == "scale" {
+				bandMetadata.Scale = value
+			} else {
+				bandMetadata.Offset = value
+			}
+		case "description":
+			bandMetadata.Description = item.Value
+		case "unittype":
+			bandMetadata.UnitType = item.Value
+		default:
+			bandMetadata.Items[item.Name] = item.Value
+		}
+	}
+	return gdalMetadata, nil
+}
+
+// scaleOffset returns sample from band with band's scale and offset applied.
+func (m *GDALMetadata) scaleOffset(sample float64, band int) float64 {
+	if band >= len(m.Bands) {
+		return sample
+	}
+	bandMetadata := &m.Bands[band]
+	return sample*bandMetadata.Scale + bandMetadata.Offset
+}
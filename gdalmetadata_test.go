@@ -0,0 +1,143 @@
+package elevation
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestParseGDALMetadata(t *testing.T) {
+	sample := func(sample int) *int {
+		return &sample
+	}
+	for _, tc := range []struct {
+		name        string
+		data        string
+		bands       int
+		expected    *GDALMetadata
+		expectedErr bool
+	}{
+		{
+			name:  "empty",
+			data:  "<GDALMetadata></GDALMetadata>",
+			bands: 1,
+			expected: &GDALMetadata{
+				Dataset: map[string]string{},
+				Bands: []GDALBandMetadata{
+					{
+						Scale: 1,
+						Items: map[string]string{},
+					},
+				},
+			},
+		},
+		{
+			name: "gdal",
+			data: "<GDALMetadata>\n" +
+				"  <Item name=\"AREA_OR_POINT\">Area</Item>\n" +
+				"  <Item name=\"COMPRESSION\" domain=\"IMAGE_STRUCTURE\">DEFLATE</Item>\n" +
+				"  <Item name=\"STATISTICS_MAXIMUM\" sample=\"0\">4807.5</Item>\n" +
+				"  <Item name=\"OFFSET\" sample=\"0\" role=\"offset\">-100</Item>\n" +
+				"  <Item name=\"SCALE\" sample=\"0\" role=\"scale\">0.1</Item>\n" +
+				"  <Item name=\"DESCRIPTION\" sample=\"0\" role=\"description\">Elevation</Item>\n" +
+				"  <Item name=\"UNITTYPE\" sample=\"0\" role=\"unittype\">m</Item>\n" +
+				"  <Item name=\"DESCRIPTION\" sample=\"2\" role=\"description\">Uncertainty</Item>\n" +
+				"</GDALMetadata>\n\x00",
+			bands: 3,
+			expected: &GDALMetadata{
+				Dataset: map[string]string{
+					"AREA_OR_POINT": "Area",
+				},
+				Bands: []GDALBandMetadata{
+					{
+						Scale:       0.1,
+						Offset:      -100,
+						Description: "Elevation",
+						UnitType:    "m",
+						Items: map[string]string{
+							"STATISTICS_MAXIMUM": "4807.5",
+						},
+					},
+					{
+						Scale: 1,
+						Items: map[string]string{},
+					},
+					{
+						Scale:       1,
+						Description: "Uncertainty",
+						Items:       map[string]string{},
+					},
+				},
+				Items: []GDALMetadataItem{
+					{Name: "AREA_OR_POINT", Value: "Area"},
+					{Name: "COMPRESSION", Domain: "IMAGE_STRUCTURE", Value: "DEFLATE"},
+					{Name: "STATISTICS_MAXIMUM", Sample: sample(0), Value: "4807.5"},
+					{Name: "OFFSET", Role: "offset", Sample: sample(0), Value: "-100"},
+					{Name: "SCALE", Role: "scale", Sample: sample(0), Value: "0.1"},
+					{Name: "DESCRIPTION", Role: "description", Sample: sample(0), Value: "Elevation"},
+					{Name: "UNITTYPE", Role: "unittype", Sample: sample(0), Value: "m"},
+					{Name: "DESCRIPTION", Role: "description", Sample: sample(2), Value: "Uncertainty"},
+				},
+			},
+		},
+		{
+			name:  "no_role",
+			data:  `<GDALMetadata><Item name="SCALE" sample="0">2</Item></GDALMetadata>`,
+			bands: 1,
+			expected: &GDALMetadata{
+				Dataset: map[string]string{},
+				Bands: []GDALBandMetadata{
+					{
+						Scale: 2,
+						Items: map[string]string{},
+					},
+				},
+				Items: []GDALMetadataItem{
+					{Name: "SCALE", Sample: sample(0), Value: "2"},
+				},
+			},
+		},
+		{
+			name: "out_of_range_samples",
+			data: "<GDALMetadata>" +
+				`<Item name="SCALE" sample="-1" role="scale">2</Item>` +
+				`<Item name="SCALE" sample="1000000000" role="scale">3</Item>` +
+				"</GDALMetadata>",
+			bands: 1,
+			expected: &GDALMetadata{
+				Dataset: map[string]string{},
+				Bands: []GDALBandMetadata{
+					{
+						Scale: 1,
+						Items: map[string]string{},
+					},
+				},
+				Items: []GDALMetadataItem{
+					{Name: "SCALE", Role: "scale", Sample: sample(-1), Value: "2"},
+					{Name: "SCALE", Role: "scale", Sample: sample(1000000000), Value: "3"},
+				},
+			},
+		},
+		{
+			name:        "invalid_xml",
+			data:        "<GDALMetadata>",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid_scale",
+			data:        `<GDALMetadata><Item name="SCALE" sample="0" role="scale">x</Item></GDALMetadata>`,
+			bands:       1,
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseGDALMetadata(tc.data, tc.bands)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package elevation
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var errParse = errors.New("parse error")
 
@@ -57,34 +60,79 @@ const (
 	GeoKeyVerticalUnits    GeoKey = 4099
 )
 
+// GTModelTypeGeoKey values.
+const (
+	ModelTypeProjected  = 1
+	ModelTypeGeographic = 2
+	ModelTypeGeocentric = 3
+)
+
+// GTRasterTypeGeoKey values.
+const (
+	RasterPixelIsArea  = 1
+	RasterPixelIsPoint = 2
+)
+
+// ProjLinearUnitsGeoKey and GeogLinearUnitsGeoKey values.
+const (
+	LinearUnitMetre        = 9001
+	LinearUnitFoot         = 9002
+	LinearUnitUSSurveyFoot = 9003
+)
+
+// geoKeyUserDefined is the GeoKey value for user-defined parameters.
+const geoKeyUserDefined = 32767
+
+// A GeoKeyError is an error parsing a GeoKeyDirectoryTag.
+type GeoKeyError struct {
+	Key GeoKey // Key that could not be parsed, or zero for the header.
+	Err error
+}
+
+func (e *GeoKeyError) Error() string {
+	if e.Key == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("GeoKey %d: %v", e.Key, e.Err)
+}
+
+func (e *GeoKeyError) Unwrap() error {
+	return e.Err
+}
+
+// A ParsedGeoKeys contains parsed GeoKeys, keyed by where their values are
+// stored.
 type ParsedGeoKeys struct {
 	Params       map[GeoKey]int
-	DoubleParams map[GeoKey]float64
+	DoubleParams map[GeoKey][]float64
 	ASCIIParams  map[GeoKey]string
 }
 
+// ParseGeoKeys parses the values of the GeoKeyDirectoryTag,
+// GeoDoubleParamsTag, and GeoASCIIParamsTag TIFF tags. Errors are of type
+// *[GeoKeyError].
 func ParseGeoKeys(directory []uint16, doubleParams []float64, asciiParams []byte) (*ParsedGeoKeys, error) {
 	if len(directory) < 4 {
-		return nil, errParse
+		return nil, &GeoKeyError{Err: fmt.Errorf("header: %d values, expected 4: %w", len(directory), errParse)}
 	}
 
 	if keyDirectoryVersion := int(directory[0]); keyDirectoryVersion != 1 {
-		return nil, errParse
+		return nil, &GeoKeyError{Err: fmt.Errorf("key directory version %d: %w", keyDirectoryVersion, errors.ErrUnsupported)}
 	}
 	if keyRevision := int(directory[1]); keyRevision != 1 {
-		return nil, errParse
+		return nil, &GeoKeyError{Err: fmt.Errorf("key revision %d: %w", keyRevision, errors.ErrUnsupported)}
 	}
 	if minorRevision := int(directory[2]); minorRevision != 0 && minorRevision != 1 {
-		return nil, errParse
+		return nil, &GeoKeyError{Err: fmt.Errorf("minor revision %d: %w", minorRevision, errors.ErrUnsupported)}
 	}
 	numberOfKeys := int(directory[3])
 	if len(directory) != 4+4*numberOfKeys {
-		return nil, errParse
+		return nil, &GeoKeyError{Err: fmt.Errorf("%d keys in %d values: %w", numberOfKeys, len(directory), errParse)}
 	}
 
 	parsedGeoKeys := &ParsedGeoKeys{
 		Params:       make(map[GeoKey]int),
-		DoubleParams: make(map[GeoKey]float64),
+		DoubleParams: make(map[GeoKey][]float64),
 		ASCIIParams:  make(map[GeoKey]string),
 	}
 	for i := range numberOfKeys {
@@ -95,21 +143,44 @@ func ParseGeoKeys(directory []uint16, doubleParams []float64, asciiParams []byte
 		switch tiffTagLocation {
 		case 0:
 			if numberOfValues != 1 {
-				return nil, errParse
+				return nil, &GeoKeyError{Key: key, Err: fmt.Errorf("%d values, expected 1: %w", numberOfValues, errParse)}
 			}
 			parsedGeoKeys.Params[key] = int(keyValues[3])
 		case 34736: // GeoDoubleParamsTag
 			index := int(keyValues[3])
-			if numberOfValues != 1 {
-				return nil, errors.ErrUnsupported
+			if index+numberOfValues > len(doubleParams) {
+				return nil, &GeoKeyError{Key: key, Err: fmt.Errorf("GeoDoubleParamsTag: index %d and count %d exceed length %d: %w", index, numberOfValues, len(doubleParams), errParse)}
 			}
-			parsedGeoKeys.DoubleParams[key] = doubleParams[index]
+			parsedGeoKeys.DoubleParams[key] = doubleParams[index : index+numberOfValues : index+numberOfValues]
 		case 34737: // GeoASCIIParamsTag
 			index := int(keyValues[3])
+			if index+numberOfValues > len(asciiParams) {
+				return nil, &GeoKeyError{Key: key, Err: fmt.Errorf("GeoASCIIParamsTag: index %d and count %d exceed length %d: %w", index, numberOfValues, len(asciiParams), errParse)}
+			}
 			parsedGeoKeys.ASCIIParams[key] = string(asciiParams[index : index+numberOfValues])
 		default:
-			return nil, errors.ErrUnsupported
+			return nil, &GeoKeyError{Key: key, Err: fmt.Errorf("TIFF tag location %d: %w", tiffTagLocation, errors.ErrUnsupported)}
 		}
 	}
 	return parsedGeoKeys, nil
 }
+
+// Double returns the value of the single-valued double parameter key and
+// whether it is set.
+func (k *ParsedGeoKeys) Double(key GeoKey) (float64, bool) {
+	values, ok := k.DoubleParams[key]
+	if !ok || len(values) != 1 {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// geoKeyCode returns the EPSG code in params for geoKey and whether it is set
+// and not user-defined.
+func geoKeyCode(params map[GeoKey]int, geoKey GeoKey) (int, bool) {
+	code, ok := params[geoKey]
+	if !ok || code == 0 || code == geoKeyUserDefined {
+		return 0, false
+	}
+	return code, true
+}
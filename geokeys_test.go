@@ -1,6 +1,9 @@
 package elevation
 
 import (
+	"encoding/binary"
+	"errors"
+	"math"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -64,15 +67,15 @@ func TestGeo_Parse(t *testing.T) {
 			GeoKeyLinearUnits2:  9001,
 			GeoKeyProjectedCRS:  32767,
 		},
-		DoubleParams: map[GeoKey]float64{
-			GeoKeyGeogAngularUnitSize:                  0.0174532925199433,
-			GeoKeyEllipsoidSemiMajorAxis:               6378137,
-			GeoKeyEllipsoidInvFlattening:               298.257222101,
-			GeoKeyPrimeMeridianLongitude:               0,
-			GeoKeyFalseEastingProjLinearParameters:     4321000,
-			GeoKeyFalseNorthingProjLinearParameters:    3210000,
-			GeoKeyCenterLongitudeProjAngularParameters: 10,
-			GeoKeyCenterLatitudeProjAngularParameters:  52,
+		DoubleParams: map[GeoKey][]float64{
+			GeoKeyGeogAngularUnitSize:                  {0.0174532925199433},
+			GeoKeyEllipsoidSemiMajorAxis:               {6378137},
+			GeoKeyEllipsoidInvFlattening:               {298.257222101},
+			GeoKeyPrimeMeridianLongitude:               {0},
+			GeoKeyFalseEastingProjLinearParameters:     {4321000},
+			GeoKeyFalseNorthingProjLinearParameters:    {3210000},
+			GeoKeyCenterLongitudeProjAngularParameters: {10},
+			GeoKeyCenterLatitudeProjAngularParameters:  {52},
 		},
 		ASCIIParams: map[GeoKey]string{
 			GeoKeyGTCitation:   "PCS Name = ETRS89_ETRS_LAEA|",
@@ -81,3 +84,161 @@ func TestGeo_Parse(t *testing.T) {
 		},
 	}, actual)
 }
+
+func TestParseGeoKeys_MultipleDoubles(t *testing.T) {
+	directory := []uint16{
+		1, 1, 0, 2,
+		2057, 34736, 1, 0,
+		3078, 34736, 2, 1,
+	}
+	doubleParams := []float64{6378137, 49, 44}
+
+	actual, err := ParseGeoKeys(directory, doubleParams, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[GeoKey][]float64{
+		GeoKeyEllipsoidSemiMajorAxis:                       {6378137},
+		GeoKeyStandardParallel1GeoKeyProjAngularParameters: {49, 44},
+	}, actual.DoubleParams)
+
+	semiMajorAxis, ok := actual.Double(GeoKeyEllipsoidSemiMajorAxis)
+	assert.True(t, ok)
+	assert.Equal(t, 6378137.0, semiMajorAxis)
+	_, ok = actual.Double(GeoKeyStandardParallel1GeoKeyProjAngularParameters)
+	assert.False(t, ok)
+	_, ok = actual.Double(GeoKeyEllipsoidSemiMinorAxis)
+	assert.False(t, ok)
+}
+
+func TestParseGeoKeys_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		directory      []uint16
+		expectedKey    GeoKey
+		expectedErr    error
+		expectedString string
+	}{
+		{
+			name:           "short_header",
+			directory:      []uint16{1, 1, 0},
+			expectedErr:    errParse,
+			expectedString: "header: 3 values, expected 4: parse error",
+		},
+		{
+			name:           "key_directory_version",
+			directory:      []uint16{2, 1, 0, 0},
+			expectedErr:    errors.ErrUnsupported,
+			expectedString: "key directory version 2: unsupported operation",
+		},
+		{
+			name:        "key_revision",
+			directory:   []uint16{1, 2, 0, 0},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name:        "minor_revision",
+			directory:   []uint16{1, 1, 2, 0},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name:        "number_of_keys",
+			directory:   []uint16{1, 1, 0, 2, 1024, 0, 1, 1},
+			expectedErr: errParse,
+		},
+		{
+			name:           "short_value_count",
+			directory:      []uint16{1, 1, 0, 1, 1024, 0, 2, 1},
+			expectedKey:    GeoKeyGTModelType,
+			expectedErr:    errParse,
+			expectedString: "GeoKey 1024: 2 values, expected 1: parse error",
+		},
+		{
+			name:           "double_index",
+			directory:      []uint16{1, 1, 0, 1, 2057, 34736, 1, 1},
+			expectedKey:    GeoKeyEllipsoidSemiMajorAxis,
+			expectedErr:    errParse,
+			expectedString: "GeoKey 2057: GeoDoubleParamsTag: index 1 and count 1 exceed length 1: parse error",
+		},
+		{
+			name:        "double_count",
+			directory:   []uint16{1, 1, 0, 1, 3078, 34736, 2, 0},
+			expectedKey: GeoKeyStandardParallel1GeoKeyProjAngularParameters,
+			expectedErr: errParse,
+		},
+		{
+			name:        "ascii_index",
+			directory:   []uint16{1, 1, 0, 1, 1026, 34737, 1, 4},
+			expectedKey: GeoKeyGTCitation,
+			expectedErr: errParse,
+		},
+		{
+			name:        "ascii_count",
+			directory:   []uint16{1, 1, 0, 1, 1026, 34737, 5, 0},
+			expectedKey: GeoKeyGTCitation,
+			expectedErr: errParse,
+		},
+		{
+			name:           "tiff_tag_location",
+			directory:      []uint16{1, 1, 0, 1, 1026, 34735, 1, 0},
+			expectedKey:    GeoKeyGTCitation,
+			expectedErr:    errors.ErrUnsupported,
+			expectedString: "GeoKey 1026: TIFF tag location 34735: unsupported operation",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseGeoKeys(tc.directory, []float64{0}, []byte("abc|"))
+			var geoKeyError *GeoKeyError
+			assert.True(t, errors.As(err, &geoKeyError))
+			assert.Equal(t, tc.expectedKey, geoKeyError.Key)
+			assert.IsError(t, err, tc.expectedErr)
+			if tc.expectedString != "" {
+				assert.EqualError(t, err, tc.expectedString)
+			}
+		})
+	}
+}
+
+func FuzzParseGeoKeys(f *testing.F) {
+	f.Add(
+		encodeFuzzGeoKeyDirectory([]uint16{1, 1, 0, 3, 1024, 0, 1, 1, 1026, 34737, 4, 0, 3078, 34736, 2, 0}),
+		encodeFuzzGeoDoubleParams([]float64{49, 44}),
+		"abc|",
+	)
+	f.Add(encodeFuzzGeoKeyDirectory([]uint16{1, 1, 0, 1, 2057, 34736, 1, 65535}), []byte{}, "")
+	f.Fuzz(func(t *testing.T, directoryData, doubleParamsData []byte, asciiParams string) {
+		directory := make([]uint16, len(directoryData)/2)
+		for i := range directory {
+			directory[i] = binary.LittleEndian.Uint16(directoryData[2*i:])
+		}
+		doubleParams := make([]float64, len(doubleParamsData)/8)
+		for i := range doubleParams {
+			doubleParams[i] = math.Float64frombits(binary.LittleEndian.Uint64(doubleParamsData[8*i:]))
+		}
+		parsedGeoKeys, err := ParseGeoKeys(directory, doubleParams, []byte(asciiParams))
+		if err != nil {
+			var geoKeyError *GeoKeyError
+			assert.True(t, errors.As(err, &geoKeyError))
+			return
+		}
+		assert.True(t, len(parsedGeoKeys.Params)+len(parsedGeoKeys.DoubleParams)+len(parsedGeoKeys.ASCIIParams) <= int(directory[3]))
+	})
+}
+
+// encodeFuzzGeoKeyDirectory returns directory encoded as FuzzParseGeoKeys
+// expects.
+func encodeFuzzGeoKeyDirectory(directory []uint16) []byte {
+	data := make([]byte, 0, 2*len(directory))
+	for _, value := range directory {
+		data = binary.LittleEndian.AppendUint16(data, value)
+	}
+	return data
+}
+
+// encodeFuzzGeoDoubleParams returns doubleParams encoded as FuzzParseGeoKeys
+// expects.
+func encodeFuzzGeoDoubleParams(doubleParams []float64) []byte {
+	data := make([]byte, 0, 8*len(doubleParams))
+	for _, value := range doubleParams {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(value))
+	}
+	return data
+}
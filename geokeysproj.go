@@ -0,0 +1,274 @@
+package elevation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ProjCoordTransGeoKey values.
+const (
+	CTTransverseMercator   = 1
+	CTLambertConfConic2SP  = 8
+	CTLambertConfConic1SP  = 9
+	CTLambertAzimEqualArea = 10
+	CTAlbersEqualArea      = 11
+	CTPolarStereographic   = 15
+)
+
+// GeogAngularUnitsGeoKey values.
+const (
+	angularUnitRadian = 9101
+	angularUnitDegree = 9102
+)
+
+// projEllipsoids maps EPSG ellipsoid codes to PROJ ellipsoid names.
+var projEllipsoids = map[int]string{
+	7001: "airy",
+	7002: "mod_airy",
+	7004: "bessel",
+	7008: "clrk66",
+	7019: "GRS80",
+	7022: "intl",
+	7030: "WGS84",
+	7043: "WGS72",
+}
+
+// projDatums maps EPSG geodetic CRS and datum codes to PROJ datum parameters,
+// which include the ellipsoid and the shift to WGS 84. PROJ shifts NAD27 using
+// grids, if they are installed.
+var projDatums = map[int]string{
+	4258: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0", 6258: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0", // ETRS89
+	4267: "+datum=NAD27", 6267: "+datum=NAD27", // NAD27
+	4269: "+datum=NAD83", 6269: "+datum=NAD83", // NAD83
+	4326: "+datum=WGS84", 6326: "+datum=WGS84", // WGS 84
+}
+
+// projLinearUnits maps EPSG linear unit codes to PROJ unit names and their
+// size in metres.
+var projLinearUnits = map[int]struct {
+	name   string
+	metres float64
+}{
+	LinearUnitMetre:        {name: "m", metres: 1},
+	LinearUnitFoot:         {name: "ft", metres: 0.3048},
+	LinearUnitUSSurveyFoot: {name: "us-ft", metres: 1200.0 / 3937.0},
+}
+
+// PROJString returns a PROJ string for k's CRS, suitable for
+// [github.com/twpayne/go-proj/v11.NewCRSToCRS]. CRSs with an EPSG code are
+// returned as "EPSG:code". User-defined geographic CRSs and user-defined
+// projected CRSs using common projection methods are built from k's
+// parameters.
+//
+// The datum of a user-defined CRS is taken from its geodetic CRS or datum
+// code, including its shift to WGS 84, so that PROJ transforms it correctly.
+// Only ETRS89, NAD27, NAD83, and WGS 84 are supported, and other codes return
+// an error wrapping [errors.ErrUnsupported]. Datums that are themselves
+// user-defined have no code and are described only by their ellipsoid, as GDAL
+// does, so PROJ treats them as unknown datums and does not shift them.
+func (k *ParsedGeoKeys) PROJString() (string, error) {
+	switch modelType := k.Params[GeoKeyGTModelType]; modelType {
+	case ModelTypeProjected:
+		if epsg, ok := geoKeyCode(k.Params, GeoKeyProjectedCRS); ok {
+			return "EPSG:" + strconv.Itoa(epsg), nil
+		}
+		return k.projectedPROJString()
+	case ModelTypeGeographic:
+		if epsg, ok := geoKeyCode(k.Params, GeoKeyGeodeticCRS); ok {
+			return "EPSG:" + strconv.Itoa(epsg), nil
+		}
+		datum, err := k.projDatum()
+		if err != nil {
+			return "", err
+		}
+		return "+proj=longlat " + datum + " +no_defs +type=crs", nil
+	default:
+		return "", fmt.Errorf("model type %d: %w", modelType, errors.ErrUnsupported)
+	}
+}
+
+// projectedPROJString returns a PROJ string for k's user-defined projected
+// CRS.
+func (k *ParsedGeoKeys) projectedPROJString() (string, error) {
+	degrees, err := k.projAngularUnitDegrees()
+	if err != nil {
+		return "", err
+	}
+	unitsParam, metres, err := k.projLinearUnits()
+	if err != nil {
+		return "", err
+	}
+	datum, err := k.projDatum()
+	if err != nil {
+		return "", err
+	}
+
+	// Parameters are read from the first of their keys that is set, as
+	// different writers use different keys for the same parameter.
+	angle := func(keys ...GeoKey) float64 {
+		value, _ := k.firstDouble(keys...)
+		return value * degrees
+	}
+	length := func(keys ...GeoKey) float64 {
+		value, _ := k.firstDouble(keys...)
+		return value * metres
+	}
+	scale := func(keys ...GeoKey) float64 {
+		if value, ok := k.firstDouble(keys...); ok {
+			return value
+		}
+		return 1
+	}
+
+	var params []string
+	param := func(name string, value float64) {
+		params = append(params, "+"+name+"="+strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	switch projMethod := k.Params[GeoKeyProjMethod]; projMethod {
+	case CTTransverseMercator:
+		params = append(params, "+proj=tmerc")
+		param("lat_0", angle(GeoKeyNaturalOriginLatitudeProjAngularParameters))
+		param("lon_0", angle(GeoKeyNaturalOriginLongitudeProjAngularParameters))
+		param("k_0", scale(GeoKeyScaleAtNaturalOriginProjScalarParameters))
+		param("x_0", length(GeoKeyFalseEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseNorthingProjLinearParameters))
+	case CTLambertConfConic1SP:
+		lat0 := angle(GeoKeyNaturalOriginLatitudeProjAngularParameters)
+		params = append(params, "+proj=lcc")
+		param("lat_1", lat0)
+		param("lat_0", lat0)
+		param("lon_0", angle(GeoKeyNaturalOriginLongitudeProjAngularParameters))
+		param("k_0", scale(GeoKeyScaleAtNaturalOriginProjScalarParameters))
+		param("x_0", length(GeoKeyFalseEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseNorthingProjLinearParameters))
+	case CTLambertConfConic2SP:
+		params = append(params, "+proj=lcc")
+		param("lat_0", angle(GeoKeyFalseOriginLatitudeProjAngularParameters, GeoKeyNaturalOriginLatitudeProjAngularParameters))
+		param("lon_0", angle(GeoKeyFalseOriginLongitudeProjAngularParameters, GeoKeyNaturalOriginLongitudeProjAngularParameters))
+		param("lat_1", angle(GeoKeyStandardParallel1GeoKeyProjAngularParameters))
+		param("lat_2", angle(GeoKeyStandardParallel2GeoKeyProjAngularParameters))
+		param("x_0", length(GeoKeyFalseOriginEastingProjLinearParameters, GeoKeyFalseEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseOriginNorthingProjLinearParameters, GeoKeyFalseNorthingProjLinearParameters))
+	case CTLambertAzimEqualArea:
+		params = append(params, "+proj=laea")
+		param("lat_0", angle(GeoKeyCenterLatitudeProjAngularParameters, GeoKeyNaturalOriginLatitudeProjAngularParameters))
+		param("lon_0", angle(GeoKeyCenterLongitudeProjAngularParameters, GeoKeyNaturalOriginLongitudeProjAngularParameters))
+		param("x_0", length(GeoKeyFalseEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseNorthingProjLinearParameters))
+	case CTAlbersEqualArea:
+		params = append(params, "+proj=aea")
+		param("lat_0", angle(GeoKeyNaturalOriginLatitudeProjAngularParameters, GeoKeyFalseOriginLatitudeProjAngularParameters))
+		param("lon_0", angle(GeoKeyNaturalOriginLongitudeProjAngularParameters, GeoKeyFalseOriginLongitudeProjAngularParameters, GeoKeyCenterLongitudeProjAngularParameters))
+		param("lat_1", angle(GeoKeyStandardParallel1GeoKeyProjAngularParameters))
+		param("lat_2", angle(GeoKeyStandardParallel2GeoKeyProjAngularParameters))
+		param("x_0", length(GeoKeyFalseEastingProjLinearParameters, GeoKeyFalseOriginEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseNorthingProjLinearParameters, GeoKeyFalseOriginNorthingProjLinearParameters))
+	case CTPolarStereographic:
+		// The latitude is either the pole, with a scale factor (EPSG variant
+		// A), or the latitude of true scale (EPSG variant B).
+		lat := angle(GeoKeyNaturalOriginLatitudeProjAngularParameters, GeoKeyStandardParallel1GeoKeyProjAngularParameters)
+		params = append(params, "+proj=stere")
+		if math.Abs(lat) == 90 {
+			param("lat_0", lat)
+		} else {
+			param("lat_0", math.Copysign(90, lat))
+			param("lat_ts", lat)
+		}
+		param("lon_0", angle(GeoKeyStraightVerticalPoleProjAngularParameters, GeoKeyNaturalOriginLongitudeProjAngularParameters))
+		if math.Abs(lat) == 90 {
+			param("k_0", scale(GeoKeyScaleAtNaturalOriginProjScalarParameters))
+		}
+		param("x_0", length(GeoKeyFalseEastingProjLinearParameters))
+		param("y_0", length(GeoKeyFalseNorthingProjLinearParameters))
+	default:
+		return "", fmt.Errorf("projection method %d: %w", projMethod, errors.ErrUnsupported)
+	}
+	params = append(params, datum, unitsParam, "+no_defs", "+type=crs")
+	return strings.Join(params, " "), nil
+}
+
+// firstDouble returns the value of the first single-valued double parameter
+// in keys that is set.
+func (k *ParsedGeoKeys) firstDouble(keys ...GeoKey) (float64, bool) {
+	for _, key := range keys {
+		if value, ok := k.Double(key); ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// projAngularUnitDegrees returns the size of k's angular unit in degrees.
+func (k *ParsedGeoKeys) projAngularUnitDegrees() (float64, error) {
+	switch angularUnits, ok := k.Params[GeoKeyAngularUnits]; {
+	case !ok || angularUnits == angularUnitDegree:
+		return 1, nil
+	case angularUnits == angularUnitRadian:
+		return 180 / math.Pi, nil
+	case angularUnits == geoKeyUserDefined:
+		if radians, ok := k.Double(GeoKeyGeogAngularUnitSize); ok {
+			return radians * 180 / math.Pi, nil
+		}
+		return 0, fmt.Errorf("angular units %d: no size", angularUnits)
+	default:
+		return 0, fmt.Errorf("angular units %d: %w", angularUnits, errors.ErrUnsupported)
+	}
+}
+
+// projLinearUnits returns the PROJ parameter for k's linear units and their
+// size in metres. Metres are assumed if k has no linear units.
+func (k *ParsedGeoKeys) projLinearUnits() (string, float64, error) {
+	linearUnits, ok := k.Params[GeoKeyLinearUnits2]
+	if !ok {
+		linearUnits = LinearUnitMetre
+	}
+	if linearUnits == geoKeyUserDefined {
+		if metres, ok := k.Double(GeoKeyProjectedLinearUnitSize); ok && metres > 0 {
+			return "+to_meter=" + strconv.FormatFloat(metres, 'f', -1, 64), metres, nil
+		}
+		return "", 0, fmt.Errorf("linear units %d: no size", linearUnits)
+	}
+	if units, ok := projLinearUnits[linearUnits]; ok {
+		return "+units=" + units.name, units.metres, nil
+	}
+	return "", 0, fmt.Errorf("linear units %d: %w", linearUnits, errors.ErrUnsupported)
+}
+
+// projDatum returns the PROJ parameters for k's datum, from its geodetic CRS
+// or datum code. If both are user-defined or missing then it returns the
+// parameters for k's ellipsoid.
+func (k *ParsedGeoKeys) projDatum() (string, error) {
+	for _, key := range []GeoKey{GeoKeyGeodeticCRS, GeoKeyGeodeticDatum} {
+		code, ok := k.Params[key]
+		if !ok || code == geoKeyUserDefined {
+			continue
+		}
+		if datum, ok := projDatums[code]; ok {
+			return datum, nil
+		}
+		return "", fmt.Errorf("datum %d: %w", code, errors.ErrUnsupported)
+	}
+	return k.projEllipsoid()
+}
+
+// projEllipsoid returns the PROJ parameters for k's ellipsoid, from its
+// ellipsoid code or its ellipsoid parameters.
+func (k *ParsedGeoKeys) projEllipsoid() (string, error) {
+	if ellipsoid, ok := projEllipsoids[k.Params[GeoKeyEllipsoid]]; ok {
+		return "+ellps=" + ellipsoid, nil
+	}
+	if semiMajorAxis, ok := k.Double(GeoKeyEllipsoidSemiMajorAxis); ok {
+		a := "+a=" + strconv.FormatFloat(semiMajorAxis, 'f', -1, 64)
+		if invFlattening, ok := k.Double(GeoKeyEllipsoidInvFlattening); ok && invFlattening != 0 {
+			return a + " +rf=" + strconv.FormatFloat(invFlattening, 'f', -1, 64), nil
+		}
+		if semiMinorAxis, ok := k.Double(GeoKeyEllipsoidSemiMinorAxis); ok {
+			return a + " +b=" + strconv.FormatFloat(semiMinorAxis, 'f', -1, 64), nil
+		}
+		return "+R=" + strconv.FormatFloat(semiMajorAxis, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("ellipsoid: %w", errors.ErrUnsupported)
+}
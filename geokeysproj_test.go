@@ -0,0 +1,393 @@
+package elevation
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/twpayne/go-proj/v11"
+)
+
+func TestParsedGeoKeys_PROJString(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		params       map[GeoKey]int
+		doubleParams map[GeoKey][]float64
+		expected     string
+		expectedErr  error
+	}{
+		{
+			name: "epsg_projected",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: 3035,
+			},
+			expected: "EPSG:3035",
+		},
+		{
+			name: "epsg_geographic",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType: ModelTypeGeographic,
+				GeoKeyGeodeticCRS: 4326,
+			},
+			expected: "EPSG:4326",
+		},
+		{
+			name: "user_defined_geographic",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType: ModelTypeGeographic,
+				GeoKeyGeodeticCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:   7030,
+			},
+			expected: "+proj=longlat +ellps=WGS84 +no_defs +type=crs",
+		},
+		{
+			// EPSG:32632, WGS 84 / UTM zone 32N.
+			name: "transverse_mercator",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyGeodeticCRS:  4326,
+				GeoKeyProjMethod:   CTTransverseMercator,
+				GeoKeyLinearUnits2: LinearUnitMetre,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyNaturalOriginLatitudeProjAngularParameters:  {0},
+				GeoKeyNaturalOriginLongitudeProjAngularParameters: {9},
+				GeoKeyScaleAtNaturalOriginProjScalarParameters:    {0.9996},
+				GeoKeyFalseEastingProjLinearParameters:            {500000},
+				GeoKeyFalseNorthingProjLinearParameters:           {0},
+			},
+			expected: "+proj=tmerc +lat_0=0 +lon_0=9 +k_0=0.9996 +x_0=500000 +y_0=0 +datum=WGS84 +units=m +no_defs +type=crs",
+		},
+		{
+			name: "lambert_conformal_conic_1sp",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    7022,
+				GeoKeyProjMethod:   CTLambertConfConic1SP,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyNaturalOriginLatitudeProjAngularParameters:  {18},
+				GeoKeyNaturalOriginLongitudeProjAngularParameters: {-77},
+				GeoKeyScaleAtNaturalOriginProjScalarParameters:    {1},
+				GeoKeyFalseEastingProjLinearParameters:            {250000},
+				GeoKeyFalseNorthingProjLinearParameters:           {150000},
+			},
+			expected: "+proj=lcc +lat_1=18 +lat_0=18 +lon_0=-77 +k_0=1 +x_0=250000 +y_0=150000 +ellps=intl +units=m +no_defs +type=crs",
+		},
+		{
+			// EPSG:2154, RGF93 v1 / Lambert-93.
+			name: "lambert_conformal_conic_2sp",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    7019,
+				GeoKeyProjMethod:   CTLambertConfConic2SP,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyFalseOriginLatitudeProjAngularParameters:     {46.5},
+				GeoKeyFalseOriginLongitudeProjAngularParameters:    {3},
+				GeoKeyStandardParallel1GeoKeyProjAngularParameters: {49},
+				GeoKeyStandardParallel2GeoKeyProjAngularParameters: {44},
+				GeoKeyFalseOriginEastingProjLinearParameters:       {700000},
+				GeoKeyFalseOriginNorthingProjLinearParameters:      {6600000},
+			},
+			expected: "+proj=lcc +lat_0=46.5 +lon_0=3 +lat_1=49 +lat_2=44 +x_0=700000 +y_0=6600000 +ellps=GRS80 +units=m +no_defs +type=crs",
+		},
+		{
+			name: "lambert_conformal_conic_2sp_us_survey_feet",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyGeodeticCRS:  4269,
+				GeoKeyProjMethod:   CTLambertConfConic2SP,
+				GeoKeyLinearUnits2: LinearUnitUSSurveyFoot,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyFalseOriginLatitudeProjAngularParameters:     {33.5},
+				GeoKeyFalseOriginLongitudeProjAngularParameters:    {-118},
+				GeoKeyStandardParallel1GeoKeyProjAngularParameters: {35.4666666666667},
+				GeoKeyStandardParallel2GeoKeyProjAngularParameters: {34.0333333333333},
+				GeoKeyFalseOriginEastingProjLinearParameters:       {6561666.667},
+				GeoKeyFalseOriginNorthingProjLinearParameters:      {1640416.667},
+			},
+			expected: "+proj=lcc +lat_0=33.5 +lon_0=-118 +lat_1=35.4666666666667 +lat_2=34.0333333333333 +x_0=2000000.0001016003 +y_0=500000.00010160013 +datum=NAD83 +units=us-ft +no_defs +type=crs",
+		},
+		{
+			// EPSG:5070, NAD83 / Conus Albers.
+			name: "albers_equal_area",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:   ModelTypeProjected,
+				GeoKeyProjectedCRS:  geoKeyUserDefined,
+				GeoKeyGeodeticDatum: 6269,
+				GeoKeyProjMethod:    CTAlbersEqualArea,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyNaturalOriginLatitudeProjAngularParameters:   {23},
+				GeoKeyNaturalOriginLongitudeProjAngularParameters:  {-96},
+				GeoKeyStandardParallel1GeoKeyProjAngularParameters: {29.5},
+				GeoKeyStandardParallel2GeoKeyProjAngularParameters: {45.5},
+				GeoKeyFalseEastingProjLinearParameters:             {0},
+				GeoKeyFalseNorthingProjLinearParameters:            {0},
+			},
+			expected: "+proj=aea +lat_0=23 +lon_0=-96 +lat_1=29.5 +lat_2=45.5 +x_0=0 +y_0=0 +datum=NAD83 +units=m +no_defs +type=crs",
+		},
+		{
+			// EPSG:3413, WGS 84 / NSIDC Sea Ice Polar Stereographic North.
+			name: "polar_stereographic_variant_b",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    7030,
+				GeoKeyProjMethod:   CTPolarStereographic,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyNaturalOriginLatitudeProjAngularParameters: {70},
+				GeoKeyStraightVerticalPoleProjAngularParameters:  {-45},
+			},
+			expected: "+proj=stere +lat_0=90 +lat_ts=70 +lon_0=-45 +x_0=0 +y_0=0 +ellps=WGS84 +units=m +no_defs +type=crs",
+		},
+		{
+			// EPSG:32761, WGS 84 / UPS South (N,E).
+			name: "polar_stereographic_variant_a",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    7030,
+				GeoKeyProjMethod:   CTPolarStereographic,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyNaturalOriginLatitudeProjAngularParameters: {-90},
+				GeoKeyStraightVerticalPoleProjAngularParameters:  {0},
+				GeoKeyScaleAtNaturalOriginProjScalarParameters:   {0.994},
+				GeoKeyFalseEastingProjLinearParameters:           {2000000},
+				GeoKeyFalseNorthingProjLinearParameters:          {2000000},
+			},
+			expected: "+proj=stere +lat_0=-90 +lon_0=0 +k_0=0.994 +x_0=2000000 +y_0=2000000 +ellps=WGS84 +units=m +no_defs +type=crs",
+		},
+		{
+			name: "radians_and_user_defined_units",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyAngularUnits: angularUnitRadian,
+				GeoKeyProjMethod:   CTLambertAzimEqualArea,
+				GeoKeyLinearUnits2: geoKeyUserDefined,
+			},
+			doubleParams: map[GeoKey][]float64{
+				GeoKeyEllipsoidSemiMajorAxis:               {6371000},
+				GeoKeyProjectedLinearUnitSize:              {1000},
+				GeoKeyCenterLatitudeProjAngularParameters:  {0.5},
+				GeoKeyCenterLongitudeProjAngularParameters: {0.25},
+				GeoKeyFalseEastingProjLinearParameters:     {10},
+			},
+			expected: "+proj=laea +lat_0=28.64788975654116 +lon_0=14.32394487827058 +x_0=10000 +y_0=0 +R=6371000 +to_meter=1000 +no_defs +type=crs",
+		},
+		{
+			name: "user_defined_geographic_nad27",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:   ModelTypeGeographic,
+				GeoKeyGeodeticCRS:   geoKeyUserDefined,
+				GeoKeyGeodeticDatum: 6267,
+			},
+			expected: "+proj=longlat +datum=NAD27 +no_defs +type=crs",
+		},
+		{
+			// ED50, whose shift to WGS 84 varies across Europe.
+			name: "unsupported_datum",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyGeodeticCRS:  4230,
+				GeoKeyEllipsoid:    7022,
+				GeoKeyProjMethod:   CTTransverseMercator,
+			},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name: "no_model_type",
+			params: map[GeoKey]int{
+				GeoKeyProjectedCRS: 3035,
+			},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name: "unsupported_projection_method",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    7030,
+				GeoKeyProjMethod:   3,
+			},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name: "unsupported_ellipsoid",
+			params: map[GeoKey]int{
+				GeoKeyGTModelType:  ModelTypeProjected,
+				GeoKeyProjectedCRS: geoKeyUserDefined,
+				GeoKeyEllipsoid:    geoKeyUserDefined,
+				GeoKeyProjMethod:   CTTransverseMercator,
+			},
+			expectedErr: errors.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parsedGeoKeys := &ParsedGeoKeys{
+				Params:       tc.params,
+				DoubleParams: tc.doubleParams,
+			}
+			actual, err := parsedGeoKeys.PROJString()
+			if tc.expectedErr != nil {
+				assert.IsError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParsedGeoKeys_PROJString_LAEA(t *testing.T) {
+	// The GeoKeys written by ArcGIS for EPSG:3035, from TestGeo_Parse.
+	directory := []uint16{
+		1, 1, 0, 9,
+		1024, 0, 1, 1,
+		2048, 0, 1, 4258,
+		2054, 0, 1, 9102,
+		2056, 0, 1, 7019,
+		3072, 0, 1, 32767,
+		3075, 0, 1, 10,
+		3076, 0, 1, 9001,
+		3082, 34736, 1, 2,
+		3083, 34736, 1, 3,
+		3088, 34736, 1, 1,
+		3089, 34736, 1, 0,
+	}
+	directory[3] = uint16((len(directory) - 4) / 4)
+	doubleParams := []float64{52, 10, 4321000, 3210000}
+
+	parsedGeoKeys, err := ParseGeoKeys(directory, doubleParams, nil)
+	assert.NoError(t, err)
+	actual, err := parsedGeoKeys.PROJString()
+	assert.NoError(t, err)
+	assert.Equal(t, "+proj=laea +lat_0=52 +lon_0=10 +x_0=4321000 +y_0=3210000 +ellps=GRS80 +towgs84=0,0,0,0,0,0,0 +units=m +no_defs +type=crs", actual)
+}
+
+func TestParsedGeoKeys_PROJString_Transform(t *testing.T) {
+	// The GeoKeys written by ArcGIS for EU-DEM, from
+	// TestParsedGeoKeys_PROJString_LAEA.
+	euDEMGeoKeys, err := ParseGeoKeys([]uint16{
+		1, 1, 0, 11,
+		1024, 0, 1, 1,
+		2048, 0, 1, 4258,
+		2054, 0, 1, 9102,
+		2056, 0, 1, 7019,
+		3072, 0, 1, 32767,
+		3075, 0, 1, 10,
+		3076, 0, 1, 9001,
+		3082, 34736, 1, 2,
+		3083, 34736, 1, 3,
+		3088, 34736, 1, 1,
+		3089, 34736, 1, 0,
+	}, []float64{52, 10, 4321000, 3210000}, nil)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name          string
+		parsedGeoKeys *ParsedGeoKeys
+		epsg          string
+		coords        [][]float64
+	}{
+		{
+			name:          "eu_dem",
+			parsedGeoKeys: euDEMGeoKeys,
+			epsg:          "EPSG:3035",
+			coords: [][]float64{
+				{4321000, 3210000},
+				{4000000, 3000000},
+				{2635000, 4880000},
+				{6500000, 1400000},
+			},
+		},
+		{
+			name: "transverse_mercator",
+			parsedGeoKeys: &ParsedGeoKeys{
+				Params: map[GeoKey]int{
+					GeoKeyGTModelType:  ModelTypeProjected,
+					GeoKeyProjectedCRS: geoKeyUserDefined,
+					GeoKeyGeodeticCRS:  4326,
+					GeoKeyProjMethod:   CTTransverseMercator,
+					GeoKeyLinearUnits2: LinearUnitMetre,
+				},
+				DoubleParams: map[GeoKey][]float64{
+					GeoKeyNaturalOriginLatitudeProjAngularParameters:  {0},
+					GeoKeyNaturalOriginLongitudeProjAngularParameters: {9},
+					GeoKeyScaleAtNaturalOriginProjScalarParameters:    {0.9996},
+					GeoKeyFalseEastingProjLinearParameters:            {500000},
+					GeoKeyFalseNorthingProjLinearParameters:           {0},
+				},
+			},
+			epsg: "EPSG:32632",
+			coords: [][]float64{
+				{500000, 0},
+				{400000, 5200000},
+				{700000, 6000000},
+			},
+		},
+		{
+			name: "user_defined_geographic",
+			parsedGeoKeys: &ParsedGeoKeys{
+				Params: map[GeoKey]int{
+					GeoKeyGTModelType: ModelTypeGeographic,
+					GeoKeyGeodeticCRS: geoKeyUserDefined,
+					GeoKeyEllipsoid:   7030,
+				},
+			},
+			epsg: "EPSG:4326",
+			coords: [][]float64{
+				{0, 0},
+				{7.5, 46.5},
+				{-120, -45},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			projString, err := tc.parsedGeoKeys.PROJString()
+			assert.NoError(t, err)
+
+			actual := transformToWGS84(t, projString, tc.coords)
+			expected := transformToWGS84(t, tc.epsg, tc.coords)
+			for i := range tc.coords {
+				assert.True(t, math.Abs(actual[i][0]-expected[i][0]) < 1e-9, "%v", tc.coords[i])
+				assert.True(t, math.Abs(actual[i][1]-expected[i][1]) < 1e-9, "%v", tc.coords[i])
+			}
+		})
+	}
+
+	// EU-DEM's false origin is at 10°E, 52°N.
+	projString, err := euDEMGeoKeys.PROJString()
+	assert.NoError(t, err)
+	actual := transformToWGS84(t, projString, [][]float64{{4321000, 3210000}})
+	assert.True(t, math.Abs(actual[0][0]-10) < 1e-9)
+	assert.True(t, math.Abs(actual[0][1]-52) < 1e-9)
+}
+
+// transformToWGS84 returns coords, in crs, transformed to longitudes and
+// latitudes in EPSG:4326.
+func transformToWGS84(t *testing.T, crs string, coords [][]float64) [][]float64 {
+	t.Helper()
+	pj, err := proj.NewCRSToCRS(crs, "EPSG:4326", nil)
+	assert.NoError(t, err)
+	pj, err = pj.NormalizeForVisualization()
+	assert.NoError(t, err)
+	transformedCoords := make([][]float64, len(coords))
+	for i, coord := range coords {
+		transformedCoords[i] = []float64{coord[0], coord[1]}
+	}
+	assert.NoError(t, pj.ForwardFloat64Slices(transformedCoords))
+	return transformedCoords
+}
@@ -2,71 +2,134 @@ package elevation
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"math"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/google/tiff"
 	_ "github.com/google/tiff/bigtiff"
 	_ "github.com/google/tiff/geotiff"
 	"github.com/maypok86/otter/v2"
-	"golang.org/x/image/tiff/lzw"
 )
 
-const noDataBits = 0xff7fffff
+var errShortRead = errors.New("short read")
 
-var (
-	errShortRead = errors.New("short read")
-	noData       = math.Float32frombits(noDataBits)
+// newSubfileTypeReducedResolution is the NewSubfileType of a reduced
+// resolution version of another image in the same file.
+const newSubfileTypeReducedResolution = 1
+
+// TIFF planar configurations.
+const (
+	planarConfigurationChunky = 1
+	planarConfigurationPlanar = 2
 )
 
+// maxReadSize is the maximum size of a single read of adjacent chunks.
+const maxReadSize = 16 << 20 // 16MB.
+
 // A GeoTIFFTile is an open GeoTIFF file.
+//
+// Multi-band GeoTIFFs are supported in both chunky (interleaved) and planar
+// configurations. Sampling methods return samples from a single band, which
+// is selected with [WithBand]. [GeoTIFFTile.SampleBands] returns the samples
+// from all bands.
 type GeoTIFFTile struct {
-	file                      *os.File
+	readerAt                  io.ReaderAt
+	file                      *sharedFile
+	mmap                      bool
+	mmapData                  []byte
+	directSampleReads         bool
+	directSamples             bool
 	imageWidth                int
 	imageLength               int
 	tileWidth                 int
 	tileLength                int
 	tilesAcross               int
 	tilesDown                 int
-	tileOffsets               []uint64
-	tileByteCounts            []uint64
-	smallestTileByteCount     uint64
-	tileSampleCount           int
+	rowsPerStrip              int
+	stripGroupSizeBytes       int
+	chunksPerTile             int
+	chunkOffsets              []uint64
+	chunkByteCounts           []uint64
+	chunksPerPlane            int
+	tilePixelCount            int
 	tileByteCountUncompressed int
 	tileCacheSizeBytes        int
-	tileSamplesCache          *otter.Cache[TileCoord, []float32]
-	emptyTileBytes            []byte
+	tileSamplesCache          *otter.Cache[tileKey, []byte]
+	emptyChunkBytes           []byte
+	decompressFuncs           map[int]DecompressFunc
+	decompressFunc            DecompressFunc
+	predictor                 int
+	byteOrder                 binary.ByteOrder
+	bytesPerSample            int
+	samplesPerPixel           int
+	planar                    bool
+	band                      int
+	sampleFunc                sampleFunc
+	noData                    float64
+	noDataBytes               []byte
+	hasNoData                 bool
+	gdalMetadata              *GDALMetadata
+	gdalScaleOffset           bool
 	scaleX                    int
 	scaleY                    int
 	translateX                int
 	translateY                int
+	integerGeoreferencing     bool
+	isOverview                bool
+	headerSize                uint64
+	ifdOffset                 uint64
+	overviews                 []*GeoTIFFTile
+	geoKeys                   *ParsedGeoKeys
+	transform                 affine
+	inverseTransform          affine
 }
 
 type GeoTIFFTileOption func(*GeoTIFFTile)
 
+// A tileKey identifies a tile in a plane of samples of the image with the IFD
+// at ifdOffset. Chunky images have a single plane containing the samples of
+// all bands, planar images have one plane per band.
+type tileKey struct {
+	TileCoord
+	plane     int
+	ifdOffset uint64
+}
+
 // A geoTIFFIFD is a struct into which github.com/google/tiff can unmarshal an
-// IFD.
+// IFD. Dimensions are uint64 so that they can be stored as SHORT, LONG, or, in
+// BigTIFF files, LONG8 values.
 type geoTIFFIFD struct {
-	ImageWidth                uint16    `tiff:"field,tag=256"`
-	ImageLength               uint16    `tiff:"field,tag=257"`
-	BitsPerSample             uint16    `tiff:"field,tag=258"`
+	ImageWidth                uint64    `tiff:"field,tag=256"`
+	ImageLength               uint64    `tiff:"field,tag=257"`
+	BitsPerSample             []uint16  `tiff:"field,tag=258"`
 	Compression               uint16    `tiff:"field,tag=259"`
 	PhotometricInterpretation uint16    `tiff:"field,tag=262"`
+	StripOffsets              []uint64  `tiff:"field,tag=273"`
 	SamplesPerPixel           uint16    `tiff:"field,tag=277"`
+	RowsPerStrip              uint64    `tiff:"field,tag=278"`
+	StripByteCounts           []uint64  `tiff:"field,tag=279"`
 	PlanarConfiguration       uint16    `tiff:"field,tag=284"`
 	Predictor                 uint16    `tiff:"field,tag=317"`
-	TileWidth                 uint16    `tiff:"field,tag=322"`
-	TileLength                uint16    `tiff:"field,tag=323"`
+	TileWidth                 uint64    `tiff:"field,tag=322"`
+	TileLength                uint64    `tiff:"field,tag=323"`
 	TileOffsets               []uint64  `tiff:"field,tag=324"`
 	TileByteCounts            []uint64  `tiff:"field,tag=325"`
-	SampleFormat              uint16    `tiff:"field,tag=339"`
+	SampleFormat              []uint16  `tiff:"field,tag=339"`
 	ModelPixelScaleTag        []float64 `tiff:"field,tag=33550"`
 	ModelTiepointTag          []float64 `tiff:"field,tag=33922"`
+	ModelTransformationTag    []float64 `tiff:"field,tag=34264"`
 	GeoKeyDirectoryTag        []uint16  `tiff:"field,tag=34735"`
 	GeoDoubleParamsTag        []float64 `tiff:"field,tag=34736"`
 	GeoASCIIParamsTag         string    `tiff:"field,tag=34737"`
@@ -74,268 +137,1132 @@ type geoTIFFIFD struct {
 	GDALNoData                string    `tiff:"field,tag=42113"`
 }
 
-// NewGeoTIFFTile returns a new GeoTIFFTile.
+// NewGeoTIFFTile returns a new GeoTIFFTile that reads filename from fsys.
 func NewGeoTIFFTile(fsys fs.FS, filename string, options ...GeoTIFFTileOption) (*GeoTIFFTile, error) {
-	var err error
-	ok := false
+	f := newGeoTIFFTile(options...)
+
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var readerAt io.ReaderAt
+	var size int64
+	if osFile, ok := file.(*os.File); ok && f.mmap {
+		readerAt, size = f.mmapFile(osFile)
+	}
+	if readerAt == nil {
+		readerAt, size, err = newFileReaderAt(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	// The file is shared with f's overviews, so it must be set before f is
+	// opened.
+	f.file = newSharedFile(func() error {
+		return errors.Join(f.munmap(), file.Close())
+	})
+	if err := f.open(readerAt, size); err != nil {
+		_ = f.file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// NewGeoTIFFTileFromReaderAt returns a new GeoTIFFTile that reads size bytes
+// from r. Closing the GeoTIFFTile does not close r.
+func NewGeoTIFFTileFromReaderAt(r io.ReaderAt, size int64, options ...GeoTIFFTileOption) (*GeoTIFFTile, error) {
+	f := newGeoTIFFTile(options...)
+	f.file = newSharedFile(func() error {
+		return nil
+	})
+	if err := f.open(r, size); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 
+// newGeoTIFFTile returns a new GeoTIFFTile with options.
+func newGeoTIFFTile(options ...GeoTIFFTileOption) *GeoTIFFTile {
 	f := &GeoTIFFTile{
-		tileCacheSizeBytes: 128 << 20, // 128MB.
+		tileCacheSizeBytes:  128 << 20, // 128MB.
+		stripGroupSizeBytes: 256 << 10, // 256KB.
+		decompressFuncs:     defaultDecompressFuncs,
+		gdalScaleOffset:     true,
 	}
 	for _, option := range options {
 		option(f)
 	}
+	return f
+}
 
-	file, err := fsys.Open(filename)
+// mmapFile maps file into memory and returns a reader for the mapping and its
+// size. If file cannot be mapped, it returns a nil reader.
+func (f *GeoTIFFTile) mmapFile(file *os.File) (io.ReaderAt, int64) {
+	fileInfo, err := file.Stat()
+	if err != nil || fileInfo.Size() == 0 {
+		return nil, 0
+	}
+	f.mmapData, err = mmapFile(file, fileInfo.Size())
 	if err != nil {
-		return nil, err
+		f.mmapData = nil
+		return nil, 0
 	}
-	if _, ok := file.(*os.File); !ok {
-		return nil, errors.ErrUnsupported
+	return bytes.NewReader(f.mmapData), fileInfo.Size()
+}
+
+// munmap unmaps f's file, if it is mapped.
+func (f *GeoTIFFTile) munmap() error {
+	if f.mmapData == nil {
+		return nil
 	}
-	f.file = file.(*os.File)
-	defer func() {
-		if !ok {
-			_ = f.file.Close()
+	data := f.mmapData
+	f.mmapData = nil
+	return munmap(data)
+}
+
+// A sharedFile is a file that is shared by a GeoTIFFTile and its overviews, or
+// the file of an HGTTile.
+// It counts references so that it is only closed when it has been closed and
+// all reads from it have finished. This allows a GeoTIFFTile to be closed, for
+// example when it is evicted from a cache, while other goroutines are still
+// reading from it. No new references can be added once it has been closed. A
+// nil *sharedFile is never closed.
+type sharedFile struct {
+	refs      atomic.Int64
+	closed    atomic.Bool
+	closeFunc func() error
+}
+
+// newSharedFile returns a new sharedFile with a single reference that calls
+// closeFunc when it is closed.
+func newSharedFile(closeFunc func() error) *sharedFile {
+	s := &sharedFile{
+		closeFunc: closeFunc,
+	}
+	s.refs.Store(1)
+	return s
+}
+
+// acquire adds a reference to s. It returns false if s has been closed, even
+// if other references have not yet been released.
+func (s *sharedFile) acquire() bool {
+	if s == nil {
+		return true
+	}
+	if s.closed.Load() {
+		return false
+	}
+	for {
+		refs := s.refs.Load()
+		if refs == 0 {
+			return false
+		}
+		if s.refs.CompareAndSwap(refs, refs+1) {
+			return true
 		}
-	}()
+	}
+}
+
+// release removes a reference from s, closing s if it was the last.
+func (s *sharedFile) release() error {
+	if s == nil || s.refs.Add(-1) != 0 {
+		return nil
+	}
+	return s.closeFunc()
+}
+
+// Close removes the initial reference from s. s is closed when all other
+// references have been released.
+func (s *sharedFile) Close() error {
+	if s == nil || !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return s.release()
+}
+
+// open reads the GeoTIFF header from size bytes of readerAt.
+func (f *GeoTIFFTile) open(readerAt io.ReaderAt, size int64) error {
+	var err error
+	f.readerAt = readerAt
 
-	tiffTIFF, err := tiff.Parse(f.file, tiff.GetTagSpace("GeoTIFF"), nil)
+	tiffTIFF, err := tiff.Parse(io.NewSectionReader(readerAt, 0, size), tiff.GetTagSpace("GeoTIFF"), nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	switch tiffTIFF.Order() {
+	case "II":
+		f.byteOrder = binary.LittleEndian
+	case "MM":
+		f.byteOrder = binary.BigEndian
+	default:
+		return fmt.Errorf("byte order %q: %w", tiffTIFF.Order(), errors.ErrUnsupported)
+	}
+
+	f.headerSize = 8
+	if tiffTIFF.Version() == 43 {
+		f.headerSize = 16
 	}
 
-	if len(tiffTIFF.IFDs()) != 1 {
-		return nil, fmt.Errorf("found %d IFDs, expected 1", len(tiffTIFF.IFDs()))
+	// Find the full resolution image and its reduced resolution overviews.
+	// Other subfiles, such as masks, are ignored.
+	var fullResolutionIFD tiff.IFD
+	var overviewIFDs []tiff.IFD
+	var fullResolutionIFDOffset uint64
+	var overviewIFDOffsets []uint64
+	ifdOffset := tiffTIFF.FirstOffset()
+	for _, tiffIFD := range tiffTIFF.IFDs() {
+		var subfileIFD struct {
+			NewSubfileType uint32 `tiff:"field,tag=254"`
+		}
+		if err := tiff.UnmarshalIFD(tiffIFD, &subfileIFD); err != nil {
+			return err
+		}
+		switch subfileIFD.NewSubfileType {
+		case 0:
+			if fullResolutionIFD == nil {
+				fullResolutionIFD = tiffIFD
+				fullResolutionIFDOffset = ifdOffset
+			}
+		case newSubfileTypeReducedResolution:
+			overviewIFDs = append(overviewIFDs, tiffIFD)
+			overviewIFDOffsets = append(overviewIFDOffsets, ifdOffset)
+		}
+		ifdOffset = tiffIFD.NextOffset()
+	}
+	if fullResolutionIFD == nil {
+		return errors.New("no full resolution image")
 	}
 
 	var ifd geoTIFFIFD
-	if err := tiff.UnmarshalIFD(tiffTIFF.IFDs()[0], &ifd); err != nil {
-		return nil, err
+	if err := tiff.UnmarshalIFD(fullResolutionIFD, &ifd); err != nil {
+		return err
 	}
 
-	if ifd.BitsPerSample != 32 ||
-		ifd.Compression != 5 ||
-		ifd.PhotometricInterpretation != 1 ||
-		ifd.SamplesPerPixel != 1 ||
-		ifd.PlanarConfiguration != 1 ||
-		ifd.Predictor != 1 ||
-		ifd.SampleFormat != 3 ||
-		len(ifd.ModelPixelScaleTag) != 3 || ifd.ModelPixelScaleTag[2] != 0 ||
-		len(ifd.ModelTiepointTag) != 6 || ifd.ModelTiepointTag[2] != 0 || ifd.ModelTiepointTag[5] != 0 ||
-		ifd.GDALNoData != "-3.4028234663852886e+038" {
-		return nil, errors.ErrUnsupported
+	switch {
+	case len(ifd.ModelTransformationTag) == 16:
+		f.transform = newAffineFromModelTransformation(ifd.ModelTransformationTag)
+	case len(ifd.ModelPixelScaleTag) == 3 && len(ifd.ModelTiepointTag) == 6:
+		scaleX, scaleY := ifd.ModelPixelScaleTag[0], ifd.ModelPixelScaleTag[1]
+		i, j, x, y := ifd.ModelTiepointTag[0], ifd.ModelTiepointTag[1], ifd.ModelTiepointTag[3], ifd.ModelTiepointTag[4]
+		if !(scaleX > 0) || !(scaleY > 0) || ifd.ModelPixelScaleTag[2] != 0 ||
+			ifd.ModelTiepointTag[2] != 0 || ifd.ModelTiepointTag[5] != 0 {
+			return errors.ErrUnsupported
+		}
+		f.transform = newAffineFromTiepoint(scaleX, scaleY, i, j, x, y)
+	default:
+		return errors.ErrUnsupported
 	}
 
-	f.imageWidth = int(ifd.ImageWidth)
-	f.imageWidth = int(ifd.ImageWidth)
-	f.imageLength = int(ifd.ImageLength)
-	f.tileWidth = int(ifd.TileWidth)
-	f.tileLength = int(ifd.TileLength)
-	f.tilesAcross = (f.imageWidth + f.tileWidth - 1) / f.tileWidth
-	f.tilesDown = (f.imageLength + f.tileLength - 1) / f.tileLength
-	tilesPerImage := f.tilesAcross * f.tilesDown
-	if len(ifd.TileByteCounts) != tilesPerImage || len(ifd.TileOffsets) != tilesPerImage {
-		return nil, errors.New("incorrect number of tile byte counts or offsets")
-	}
-	f.tileOffsets = ifd.TileOffsets
-	f.tileByteCounts = ifd.TileByteCounts
-	f.smallestTileByteCount = ifd.TileByteCounts[0]
-	for _, tileByteCount := range ifd.TileByteCounts[1:] {
-		if tileByteCount < f.smallestTileByteCount {
-			f.smallestTileByteCount = tileByteCount
-		}
-	}
-	f.tileSampleCount = f.tileWidth * f.tileLength
-	f.tileByteCountUncompressed = f.tileSampleCount * int(ifd.BitsPerSample) / 8
-
-	tileCacheCount := max(f.tileCacheSizeBytes/f.tileByteCountUncompressed, 1)
-	f.tileSamplesCache, err = otter.New(&otter.Options[TileCoord, []float32]{
-		MaximumSize: tileCacheCount,
+	if len(ifd.GeoKeyDirectoryTag) != 0 {
+		f.geoKeys, err = ParseGeoKeys(ifd.GeoKeyDirectoryTag, ifd.GeoDoubleParamsTag, []byte(ifd.GeoASCIIParamsTag))
+		if err != nil {
+			return fmt.Errorf("GeoKeyDirectoryTag: %w", err)
+		}
+	}
+
+	// GeoTIFF tie points and transformations map to the corner of the pixel
+	// in PixelIsArea rasters and to the center of the pixel in PixelIsPoint
+	// rasters. Like GDAL, treat all pixels as areas, moving the origin of
+	// PixelIsPoint rasters by half a pixel.
+	if f.RasterType() == RasterPixelIsPoint {
+		f.transform = f.transform.translate(-0.5, -0.5)
+	}
+
+	// Use the nodata value from the GDAL_NODATA tag, if any, unless one was
+	// set explicitly.
+	if !f.hasNoData && ifd.GDALNoData != "" {
+		f.noData, err = parseGDALNoData(ifd.GDALNoData)
+		if err != nil {
+			return err
+		}
+		f.hasNoData = true
+	}
+
+	// Malformed GDAL metadata is an error, as it might contain the scale
+	// and offset needed to interpret samples.
+	if ifd.GDALMetadata != "" {
+		f.gdalMetadata, err = ParseGDALMetadata(ifd.GDALMetadata, max(int(ifd.SamplesPerPixel), 1))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The full resolution image and its overviews share a single cache, so
+	// that the tile cache size limits the memory used by all of them. Tiles
+	// larger than the cache are weighed as the whole cache so that at least
+	// one tile is cached.
+	maximumWeight := uint64(max(f.tileCacheSizeBytes, 1))
+	f.tileSamplesCache, err = otter.New(&otter.Options[tileKey, []byte]{
+		MaximumWeight: maximumWeight,
+		Weigher: func(key tileKey, tileSamples []byte) uint32 {
+			return uint32(min(uint64(max(len(tileSamples), 1)), maximumWeight, math.MaxUint32))
+		},
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	scaleX, scaleY, scaleZ := ifd.ModelPixelScaleTag[0], ifd.ModelPixelScaleTag[1], ifd.ModelPixelScaleTag[2]
-	if scaleX != float64(int(scaleX)) || scaleY != float64(int(scaleY)) || scaleZ != 0 {
-		return nil, errors.ErrUnsupported
+	// Overviews share everything except the properties of their IFD.
+	overviewTemplate := *f
+
+	f.ifdOffset = fullResolutionIFDOffset
+	if err := f.initIFD(&ifd); err != nil {
+		return err
 	}
-	i, j, k := ifd.ModelTiepointTag[0], ifd.ModelTiepointTag[1], ifd.ModelTiepointTag[2]
-	if i != 0 || j != 0 || k != 0 {
-		return nil, errors.ErrUnsupported
+	if err := f.initTransform(f.transform); err != nil {
+		return err
 	}
-	x, y, z := ifd.ModelTiepointTag[3], ifd.ModelTiepointTag[4], ifd.ModelTiepointTag[5]
-	if x != float64(int(x)) || y != float64(int(y)) || z != 0 {
-		return nil, errors.ErrUnsupported
+
+	for i, overviewIFD := range overviewIFDs {
+		overview := overviewTemplate
+		overview.isOverview = true
+		overview.ifdOffset = overviewIFDOffsets[i]
+		var ifd geoTIFFIFD
+		if err := tiff.UnmarshalIFD(overviewIFD, &ifd); err != nil {
+			return err
+		}
+		if err := overview.initIFD(&ifd); err != nil {
+			return fmt.Errorf("overview: %w", err)
+		}
+		scaleX := float64(f.imageWidth) / float64(overview.imageWidth)
+		scaleY := float64(f.imageLength) / float64(overview.imageLength)
+		if err := overview.initTransform(f.transform.scale(scaleX, scaleY)); err != nil {
+			return fmt.Errorf("overview: %w", err)
+		}
+		f.overviews = append(f.overviews, &overview)
 	}
-	f.scaleX = int(scaleX)
-	f.scaleY = int(scaleY)
-	f.translateX = int(x)
-	f.translateY = int(y)
+	slices.SortFunc(f.overviews, func(a, b *GeoTIFFTile) int {
+		return b.imageWidth - a.imageWidth
+	})
 
-	ok = true
-	return f, nil
+	return nil
 }
 
+// initIFD initializes the properties of f that are specific to ifd.
+func (f *GeoTIFFTile) initIFD(ifd *geoTIFFIFD) error {
+	if ifd.PhotometricInterpretation != 1 {
+		return fmt.Errorf("photometric interpretation %d: %w", ifd.PhotometricInterpretation, errors.ErrUnsupported)
+	}
+
+	f.samplesPerPixel = max(int(ifd.SamplesPerPixel), 1)
+	switch ifd.PlanarConfiguration {
+	case 0, planarConfigurationChunky:
+		f.planar = false
+	case planarConfigurationPlanar:
+		f.planar = f.samplesPerPixel > 1
+	default:
+		return fmt.Errorf("planar configuration %d: %w", ifd.PlanarConfiguration, errors.ErrUnsupported)
+	}
+	if f.band < 0 || f.samplesPerPixel <= f.band {
+		return fmt.Errorf("band %d: out of range for %d bands", f.band, f.samplesPerPixel)
+	}
+
+	// All bands must have the same sample format.
+	bitsPerSample, err := uniformSampleValue(ifd.BitsPerSample, f.samplesPerPixel, 1)
+	if err != nil {
+		return fmt.Errorf("BitsPerSample: %w", err)
+	}
+	sampleFormat, err := uniformSampleValue(ifd.SampleFormat, f.samplesPerPixel, sampleFormatUint)
+	if err != nil {
+		return fmt.Errorf("SampleFormat: %w", err)
+	}
+	if sampleFormat == 0 {
+		sampleFormat = sampleFormatUint
+	}
+	f.sampleFunc, err = newSampleFunc(sampleFormat, bitsPerSample, f.byteOrder)
+	if err != nil {
+		return err
+	}
+	f.bytesPerSample = bitsPerSample / 8
+
+	// The nodata value is converted to the sample format so that it can be
+	// compared directly with samples. If the nodata value cannot be
+	// represented in the sample format then no sample can match it.
+	if f.hasNoData {
+		f.noData, f.hasNoData = sampleValue(f.noData, sampleFormat, bitsPerSample)
+	}
+	f.noDataBytes = nil
+	if f.hasNoData {
+		f.noDataBytes = sampleBytes(f.noData, sampleFormat, bitsPerSample, f.byteOrder)
+	}
+
+	f.decompressFunc = f.decompressFuncs[int(ifd.Compression)]
+	if f.decompressFunc == nil {
+		return fmt.Errorf("compression %d: %w", ifd.Compression, errors.ErrUnsupported)
+	}
+	// LERC stores samples in little-endian byte order.
+	if ifd.Compression == CompressionLERC && f.byteOrder != binary.LittleEndian {
+		return fmt.Errorf("LERC compression with byte order %s: %w", f.byteOrder, errors.ErrUnsupported)
+	}
+
+	switch ifd.Predictor {
+	case 0, predictorNone:
+		f.predictor = predictorNone
+	case predictorHorizontal:
+		f.predictor = predictorHorizontal
+	case predictorFloatingPoint:
+		if sampleFormat != sampleFormatIEEEFP {
+			return fmt.Errorf("predictor %d with sample format %d: %w", ifd.Predictor, sampleFormat, errors.ErrUnsupported)
+		}
+		f.predictor = predictorFloatingPoint
+	default:
+		return fmt.Errorf("predictor %d: %w", ifd.Predictor, errors.ErrUnsupported)
+	}
+
+	if ifd.ImageWidth == 0 || ifd.ImageWidth > math.MaxInt32 || ifd.ImageLength == 0 || ifd.ImageLength > math.MaxInt32 {
+		return fmt.Errorf("image size %dx%d: %w", ifd.ImageWidth, ifd.ImageLength, errors.ErrUnsupported)
+	}
+	f.imageWidth = int(ifd.ImageWidth)
+	f.imageLength = int(ifd.ImageLength)
+	planes := 1
+	if f.planar {
+		planes = f.samplesPerPixel
+	}
+	switch {
+	case ifd.TileWidth != 0 && ifd.TileLength != 0:
+		if ifd.TileWidth > math.MaxInt32 || ifd.TileLength > math.MaxInt32 {
+			return fmt.Errorf("tile size %dx%d: %w", ifd.TileWidth, ifd.TileLength, errors.ErrUnsupported)
+		}
+		f.tileWidth = int(ifd.TileWidth)
+		f.tileLength = int(ifd.TileLength)
+		f.tilesAcross = (f.imageWidth + f.tileWidth - 1) / f.tileWidth
+		f.tilesDown = (f.imageLength + f.tileLength - 1) / f.tileLength
+		tilesPerImage := f.tilesAcross * f.tilesDown
+		if len(ifd.TileByteCounts) != planes*tilesPerImage || len(ifd.TileOffsets) != planes*tilesPerImage {
+			return errors.New("incorrect number of tile byte counts or offsets")
+		}
+		f.chunksPerTile = 1
+		f.chunksPerPlane = tilesPerImage
+		f.chunkOffsets = ifd.TileOffsets
+		f.chunkByteCounts = ifd.TileByteCounts
+	case len(ifd.StripOffsets) != 0:
+		// Treat groups of consecutive strips as tiles that span the full
+		// width of the image, so that small strips are cached together.
+		f.rowsPerStrip = f.imageLength
+		if ifd.RowsPerStrip != 0 {
+			f.rowsPerStrip = int(min(ifd.RowsPerStrip, uint64(f.imageLength)))
+		}
+		stripsPerImage := (f.imageLength + f.rowsPerStrip - 1) / f.rowsPerStrip
+		if len(ifd.StripByteCounts) != planes*stripsPerImage || len(ifd.StripOffsets) != planes*stripsPerImage {
+			return errors.New("incorrect number of strip byte counts or offsets")
+		}
+		stripByteCountUncompressed := f.rowsPerStrip * f.imageWidth * f.samplesPerChunkPixel() * f.bytesPerSample
+		f.chunksPerTile = max(f.stripGroupSizeBytes/stripByteCountUncompressed, 1)
+		f.tileWidth = f.imageWidth
+		f.tileLength = f.chunksPerTile * f.rowsPerStrip
+		f.tilesAcross = 1
+		f.tilesDown = (stripsPerImage + f.chunksPerTile - 1) / f.chunksPerTile
+		f.chunksPerPlane = stripsPerImage
+		f.chunkOffsets = ifd.StripOffsets
+		f.chunkByteCounts = ifd.StripByteCounts
+	default:
+		return errors.New("no tiles or strips")
+	}
+	f.tilePixelCount = f.tileWidth * f.tileLength
+	f.tileByteCountUncompressed = f.tilePixelCount * f.samplesPerChunkPixel() * f.bytesPerSample
+
+	// Samples in uncompressed files without a predictor are stored
+	// individually, so they can be read without reading the whole tile.
+	f.directSamples = ifd.Compression == CompressionNone &&
+		f.predictor == predictorNone &&
+		(f.directSampleReads || f.mmapData != nil)
+
+	// Empty tiles are detected before they are decompressed by comparing
+	// their compressed bytes with an empty chunk. Uncompressed chunks all
+	// have the same size, so the empty chunk cannot be found by its size.
+	if f.hasNoData && ifd.Compression != CompressionNone {
+		f.emptyChunkBytes = f.findEmptyChunkBytes()
+	}
+	return nil
+}
+
+// findEmptyChunkBytes returns the compressed bytes of the smallest chunk if
+// all its samples are nodata. We assume that the empty chunk is the smallest
+// chunk. It is called when f is opened, rather than when tiles are decoded,
+// because tiles are decoded concurrently. Errors are ignored as they are
+// returned when the chunk's tile is sampled.
+func (f *GeoTIFFTile) findEmptyChunkBytes() []byte {
+	smallestChunkIndex := -1
+	for chunkIndex, chunkByteCount := range f.chunkByteCounts {
+		if chunkByteCount != 0 && (smallestChunkIndex == -1 || chunkByteCount < f.chunkByteCounts[smallestChunkIndex]) {
+			smallestChunkIndex = chunkIndex
+		}
+	}
+	if smallestChunkIndex == -1 || f.chunkByteCounts[smallestChunkIndex] > maxReadSize {
+		return nil
+	}
+	compressedChunk := make([]byte, f.chunkByteCounts[smallestChunkIndex])
+	if err := f.readAt(context.Background(), compressedChunk, f.chunkOffsets[smallestChunkIndex]); err != nil {
+		return nil
+	}
+	chunkSamples := make([]byte, f.chunkByteCountUncompressed(smallestChunkIndex))
+	if err := f.decompressFunc(chunkSamples, compressedChunk); err != nil {
+		return nil
+	}
+	if err := f.undoPredictor(chunkSamples); err != nil {
+		return nil
+	}
+	if !f.allNoData(chunkSamples) {
+		return nil
+	}
+	return compressedChunk
+}
+
+// initTransform sets f's transform from pixel to model coordinates.
+func (f *GeoTIFFTile) initTransform(transform affine) error {
+	f.transform = transform
+	var invertible bool
+	if f.inverseTransform, invertible = f.transform.inverse(); !invertible {
+		return errors.New("non-invertible model transformation")
+	}
+
+	// Use integer arithmetic if the georeferencing is exactly representable
+	// with integers.
+	f.integerGeoreferencing = false
+	if f.transform.isNorthUp() && isInt(f.transform.a) && isInt(f.transform.e) && isInt(f.transform.c) && isInt(f.transform.f) {
+		f.integerGeoreferencing = true
+		f.scaleX = int(f.transform.a)
+		f.scaleY = int(-f.transform.e)
+		f.translateX = int(f.transform.c)
+		f.translateY = int(f.transform.f)
+	}
+	return nil
+}
+
+// WithDecompressFunc sets the function used to decompress tiles compressed
+// with the TIFF compression scheme compression, overriding any built-in
+// decompressor.
+func WithDecompressFunc(compression int, decompressFunc DecompressFunc) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.decompressFuncs = maps.Clone(f.decompressFuncs)
+		f.decompressFuncs[compression] = decompressFunc
+	}
+}
+
+// WithNoData sets the nodata value, overriding any GDAL_NODATA tag in the
+// file. Samples equal to the nodata value are returned as NaN.
+func WithNoData(noData float64) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.noData = noData
+		f.hasNoData = true
+	}
+}
+
+// WithMmap sets whether to memory map files, so that compressed tile data is
+// read directly from the operating system's page cache. Memory mapping is only
+// supported for local files on Linux. Other files are read normally.
+func WithMmap(mmap bool) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.mmap = mmap
+	}
+}
+
+// WithDirectSampleReads sets whether to read samples from uncompressed files
+// directly from the file, rather than reading and caching whole tiles. This
+// makes sparse lookups in large uncompressed files much cheaper, but makes
+// dense lookups more expensive. Samples are always read directly from
+// uncompressed files that are memory mapped.
+func WithDirectSampleReads(directSampleReads bool) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.directSampleReads = directSampleReads
+	}
+}
+
+// WithGDALScaleOffset sets whether to apply the per-band scale and offset from
+// the file's GDAL metadata to samples. The default is true. The nodata value is
+// compared with samples before they are scaled.
+func WithGDALScaleOffset(gdalScaleOffset bool) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.gdalScaleOffset = gdalScaleOffset
+	}
+}
+
+// WithBand sets the band that is sampled, starting from zero. The default is
+// the first band.
+func WithBand(band int) GeoTIFFTileOption {
+	return func(f *GeoTIFFTile) {
+		f.band = band
+	}
+}
+
+// WithTileCacheSize sets the maximum size in bytes of the decoded tiles cached
+// by f and its overviews. The default is 128MB.
 func WithTileCacheSize(tileCacheSize int) GeoTIFFTileOption {
 	return func(f *GeoTIFFTile) {
 		f.tileCacheSizeBytes = tileCacheSize
 	}
 }
 
+// Close closes f. Overviews share f's file and are closed when f is closed. If
+// other goroutines are sampling f then its file is closed when they finish,
+// and subsequent samples return an error wrapping [fs.ErrClosed].
 func (f *GeoTIFFTile) Close() error {
+	if f.isOverview {
+		return nil
+	}
 	return f.file.Close()
 }
 
+// sharedTileFile returns f's file.
+func (f *GeoTIFFTile) sharedTileFile() *sharedFile {
+	return f.file
+}
+
+// Overviews returns f's reduced resolution overviews, from highest to lowest
+// resolution.
+func (f *GeoTIFFTile) Overviews() []*GeoTIFFTile {
+	return f.overviews
+}
+
+// Level returns f for level 0 and the overview at level-1 for higher levels.
+// It returns nil if there is no such level.
+func (f *GeoTIFFTile) Level(level int) *GeoTIFFTile {
+	switch {
+	case level == 0:
+		return f
+	case 0 < level && level <= len(f.overviews):
+		return f.overviews[level-1]
+	default:
+		return nil
+	}
+}
+
+// LevelForPixelScale returns the lowest resolution level whose pixels are no
+// larger than pixelScale in model coordinates. It returns 0 if no overview is
+// fine enough.
+func (f *GeoTIFFTile) LevelForPixelScale(pixelScale float64) int {
+	level := 0
+	for i, overview := range f.overviews {
+		pixelScaleX, pixelScaleY := overview.PixelScale()
+		if max(pixelScaleX, pixelScaleY) > pixelScale {
+			break
+		}
+		level = i + 1
+	}
+	return level
+}
+
+// Bands returns the number of bands in f.
+func (f *GeoTIFFTile) Bands() int {
+	return f.samplesPerPixel
+}
+
+// Size returns f's width and length in pixels.
+func (f *GeoTIFFTile) Size() (int, int) {
+	return f.imageWidth, f.imageLength
+}
+
+// GDALMetadata returns f's GDAL metadata, or nil if f has none.
+func (f *GeoTIFFTile) GDALMetadata() *GDALMetadata {
+	return f.gdalMetadata
+}
+
+// NoData returns f's nodata value and whether f has a nodata value.
+func (f *GeoTIFFTile) NoData() (float64, bool) {
+	return f.noData, f.hasNoData
+}
+
 // Sample returns a single sample from f.
 func (f *GeoTIFFTile) Sample(ctx context.Context, coord Coord) (float64, error) {
-	localCoord := f.localCoord(coord)
-	localTileCoord, ok := f.localTileCoord(localCoord)
-	if !ok {
-		return math.NaN(), nil
+	if !f.file.acquire() {
+		return 0, fs.ErrClosed
 	}
-	switch tileSamples, err := f.getTileSamplesCached(ctx, localTileCoord); {
-	case errors.Is(err, otter.ErrNotFound):
-		return math.NaN(), nil
-	case err != nil:
-		return 0, err
-	default:
-		return f.tileSample(tileSamples, localCoord), nil
+	defer f.file.release()
+	return f.localSample(ctx, f.localCoord(coord), f.band)
+}
+
+// SampleBands returns the samples from all of f's bands at coord.
+func (f *GeoTIFFTile) SampleBands(ctx context.Context, coord Coord) ([]float64, error) {
+	if !f.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer f.file.release()
+	return f.localSampleBands(ctx, f.localCoord(coord))
+}
+
+// SampleBandsFloat64 returns the samples from all of f's bands at a floating
+// point coordinate.
+func (f *GeoTIFFTile) SampleBandsFloat64(ctx context.Context, coord Float64Coord) ([]float64, error) {
+	if !f.file.acquire() {
+		return nil, fs.ErrClosed
 	}
+	defer f.file.release()
+	return f.localSampleBands(ctx, f.localCoordFloat64(coord))
 }
 
 // Samples returns multiple samples from f. It is significantly faster than
 // calling [Sample] for each coordinate.
 func (f *GeoTIFFTile) Samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	if !f.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer f.file.release()
+	return f.samples(ctx, coords)
+}
+
+// SampleFloat64 returns a single sample from f at a floating point coordinate.
+func (f *GeoTIFFTile) SampleFloat64(ctx context.Context, coord Float64Coord) (float64, error) {
+	if !f.file.acquire() {
+		return 0, fs.ErrClosed
+	}
+	defer f.file.release()
+	return f.localSample(ctx, f.localCoordFloat64(coord), f.band)
+}
+
+// SamplesFloat64 returns multiple samples from f at floating point
+// coordinates.
+func (f *GeoTIFFTile) SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	if !f.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer f.file.release()
+	return f.samplesFloat64(ctx, coords)
+}
+
+// samples is like Samples but the caller must hold a reference to f's file.
+func (f *GeoTIFFTile) samples(ctx context.Context, coords []Coord) ([]float64, error) {
 	localCoords := make([]Coord, len(coords))
 	for i, coord := range coords {
 		localCoords[i] = f.localCoord(coord)
 	}
+	return f.localSamples(ctx, localCoords)
+}
+
+// samplesFloat64 is like SamplesFloat64 but the caller must hold a reference
+// to f's file.
+func (f *GeoTIFFTile) samplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	localCoords := make([]Coord, len(coords))
+	for i, coord := range coords {
+		localCoords[i] = f.localCoordFloat64(coord)
+	}
+	return f.localSamples(ctx, localCoords)
+}
+
+// RasterType returns f's raster type, either [RasterPixelIsArea] or
+// [RasterPixelIsPoint].
+func (f *GeoTIFFTile) RasterType() int {
+	if f.geoKeys != nil {
+		if rasterType, ok := f.geoKeys.Params[GeoKeyGTRasterType]; ok {
+			return rasterType
+		}
+	}
+	return RasterPixelIsArea
+}
+
+// GeoKeys returns f's GeoKeys, or nil if f has none.
+func (f *GeoTIFFTile) GeoKeys() *ParsedGeoKeys {
+	return f.geoKeys
+}
+
+// EPSG returns the EPSG code of f's CRS and whether it is known. The code is
+// f's ProjectedCRSGeoKey, or its GeodeticCRSGeoKey if f is not projected.
+// User-defined CRSs are not known.
+func (f *GeoTIFFTile) EPSG() (int, bool) {
+	if f.geoKeys == nil {
+		return 0, false
+	}
+	modelType := f.geoKeys.Params[GeoKeyGTModelType]
+	if modelType != ModelTypeGeographic {
+		if epsg, ok := geoKeyCode(f.geoKeys.Params, GeoKeyProjectedCRS); ok {
+			return epsg, true
+		}
+	}
+	if modelType != ModelTypeProjected {
+		if epsg, ok := geoKeyCode(f.geoKeys.Params, GeoKeyGeodeticCRS); ok {
+			return epsg, true
+		}
+	}
+	return 0, false
+}
+
+// LinearUnits returns the EPSG code of f's linear units, for example
+// [LinearUnitMetre], and whether they are known.
+func (f *GeoTIFFTile) LinearUnits() (int, bool) {
+	if f.geoKeys == nil {
+		return 0, false
+	}
+	if linearUnits, ok := geoKeyCode(f.geoKeys.Params, GeoKeyLinearUnits2); ok {
+		return linearUnits, true
+	}
+	return geoKeyCode(f.geoKeys.Params, GeoKeyLinearUnits)
+}
+
+// PixelScale returns the size of f's pixels in model coordinates.
+func (f *GeoTIFFTile) PixelScale() (float64, float64) {
+	return math.Hypot(f.transform.a, f.transform.d), math.Hypot(f.transform.b, f.transform.e)
+}
+
+// localSample returns the sample of band at localCoord. The caller must hold a
+// reference to f's file.
+func (f *GeoTIFFTile) localSample(ctx context.Context, localCoord Coord, band int) (float64, error) {
+	localTileCoord, ok := f.localTileCoord(localCoord)
+	if !ok {
+		return math.NaN(), nil
+	}
+	key := f.tileKey(localTileCoord, band)
+	if f.directSamples {
+		return f.readSample(ctx, key, localCoord, band)
+	}
+	switch tileSamples, err := f.getTileSamplesCached(ctx, key); {
+	case err != nil:
+		return 0, err
+	case len(tileSamples) == 0:
+		return math.NaN(), nil
+	default:
+		return f.tileSample(tileSamples, localCoord, band), nil
+	}
+}
+
+// localSampleBands returns the samples from all of f's bands at localCoord.
+// The caller must hold a reference to f's file.
+func (f *GeoTIFFTile) localSampleBands(ctx context.Context, localCoord Coord) ([]float64, error) {
+	samples := make([]float64, f.samplesPerPixel)
+	for band := range samples {
+		sample, err := f.localSample(ctx, localCoord, band)
+		if err != nil {
+			return nil, err
+		}
+		samples[band] = sample
+	}
+	return samples, nil
+}
 
+// localSamples returns the samples at localCoords. The caller must hold a
+// reference to f's file.
+func (f *GeoTIFFTile) localSamples(ctx context.Context, localCoords []Coord) ([]float64, error) {
 	samples := make([]float64, len(localCoords))
 
-	// Group indexes by local tile coord.
-	indexesByLocalTileCoord := make(map[TileCoord][]int)
+	if f.directSamples {
+		for index, localCoord := range localCoords {
+			localTileCoord, ok := f.localTileCoord(localCoord)
+			if !ok {
+				samples[index] = math.NaN()
+				continue
+			}
+			sample, err := f.readSample(ctx, f.tileKey(localTileCoord, f.band), localCoord, f.band)
+			if err != nil {
+				return nil, err
+			}
+			samples[index] = sample
+		}
+		return samples, nil
+	}
+
+	// Group indexes by tile.
+	indexesByTileKey := make(map[tileKey][]int)
 	for index, localCoord := range localCoords {
 		localTileCoord, ok := f.localTileCoord(localCoord)
 		if !ok {
 			samples[index] = math.NaN()
 			continue
 		}
-		indexesByLocalTileCoord[localTileCoord] = append(indexesByLocalTileCoord[localTileCoord], index)
+		key := f.tileKey(localTileCoord, f.band)
+		indexesByTileKey[key] = append(indexesByTileKey[key], index)
 	}
 
-	// Populate samples one local tile at a time.
-	for localTileCoord, indexes := range indexesByLocalTileCoord {
-		switch tileSamples, err := f.getTileSamplesCached(ctx, localTileCoord); {
-		case errors.Is(err, otter.ErrNotFound):
-			for _, index := range indexes {
+	// Get all the tiles at once, so that tiles that are not already cached
+	// can be read together.
+	keys := slices.Collect(maps.Keys(indexesByTileKey))
+	tilesSamples, err := f.tileSamplesCache.BulkGet(ctx, keys, otter.BulkLoaderFunc[tileKey, []byte](f.getTilesSamples))
+	if err != nil {
+		return nil, err
+	}
+
+	// Populate samples one tile at a time.
+	for key, indexes := range indexesByTileKey {
+		tileSamples := tilesSamples[key]
+		for _, index := range indexes {
+			if len(tileSamples) != 0 {
+				samples[index] = f.tileSample(tileSamples, localCoords[index], f.band)
+			} else {
 				samples[index] = math.NaN()
 			}
-		case err != nil:
-			return nil, err
-		default:
-			for _, index := range indexes {
-				samples[index] = f.tileSample(tileSamples, localCoords[index])
-			}
 		}
 	}
 
 	return samples, nil
 }
 
-// getCompressedTileData returns the compressed tile data for the data at
-// localTileCoord. If the tile is known to be empty, it returns the error
-// otter.ErrNotFound.
-func (f *GeoTIFFTile) getCompressedTileData(localTileCoord TileCoord) ([]byte, error) {
-	tileIndex := localTileCoord.C + f.tilesAcross*localTileCoord.R
-	tileByteCount := f.tileByteCounts[tileIndex]
-	tileOffset := f.tileOffsets[tileIndex]
-	compressedData := make([]byte, tileByteCount)
-	switch n, err := f.file.ReadAt(compressedData, int64(tileOffset)); {
+// tileKey returns the key of the tile at localTileCoord containing the
+// samples of band.
+func (f *GeoTIFFTile) tileKey(localTileCoord TileCoord, band int) tileKey {
+	key := tileKey{
+		TileCoord: localTileCoord,
+		ifdOffset: f.ifdOffset,
+	}
+	if f.planar {
+		key.plane = band
+	}
+	return key
+}
+
+// samplesPerChunkPixel returns the number of samples per pixel in each chunk.
+func (f *GeoTIFFTile) samplesPerChunkPixel() int {
+	if f.planar {
+		return 1
+	}
+	return f.samplesPerPixel
+}
+
+// chunkIndexes returns the range of indexes of the chunks in the tile with
+// key. A chunk is a single tile in tiled images, or a single strip in stripped
+// images. Planar images store the chunks of each plane consecutively.
+func (f *GeoTIFFTile) chunkIndexes(key tileKey) (int, int) {
+	tileIndex := key.C + f.tilesAcross*key.R
+	planeChunkIndex := key.plane * f.chunksPerPlane
+	firstChunkIndex := planeChunkIndex + tileIndex*f.chunksPerTile
+	return firstChunkIndex, min(firstChunkIndex+f.chunksPerTile, planeChunkIndex+f.chunksPerPlane)
+}
+
+// chunkByteCountUncompressed returns the uncompressed size of the chunk at
+// chunkIndex. The last strip in each plane may contain fewer rows than the
+// others.
+func (f *GeoTIFFTile) chunkByteCountUncompressed(chunkIndex int) int {
+	if f.rowsPerStrip == 0 {
+		return f.tileByteCountUncompressed
+	}
+	rows := min(f.rowsPerStrip, f.imageLength-(chunkIndex%f.chunksPerPlane)*f.rowsPerStrip)
+	return rows * f.tileWidth * f.samplesPerChunkPixel() * f.bytesPerSample
+}
+
+// readCompressedTilesData returns the compressed data of each chunk in each
+// of the tiles with keys. Adjacent chunks are read with a single read, which is
+// much faster when f is read over a network.
+func (f *GeoTIFFTile) readCompressedTilesData(ctx context.Context, keys []tileKey) ([][][]byte, error) {
+	type chunkStruct struct {
+		tileIndex  int
+		chunkIndex int
+		offset     uint64
+		byteCount  uint64
+	}
+	var chunks []chunkStruct
+	compressedTilesData := make([][][]byte, len(keys))
+
+	// If f is memory mapped then slice the chunks directly from the mapping.
+	if f.mmapData != nil {
+		for tileIndex, key := range keys {
+			firstChunkIndex, endChunkIndex := f.chunkIndexes(key)
+			compressedTilesData[tileIndex] = make([][]byte, endChunkIndex-firstChunkIndex)
+			for chunkIndex := firstChunkIndex; chunkIndex < endChunkIndex; chunkIndex++ {
+				if f.chunkByteCounts[chunkIndex] == 0 {
+					continue
+				}
+				start, end := f.chunkOffsets[chunkIndex], f.chunkOffsets[chunkIndex]+f.chunkByteCounts[chunkIndex]
+				if end > uint64(len(f.mmapData)) {
+					return nil, errShortRead
+				}
+				compressedTilesData[tileIndex][chunkIndex-firstChunkIndex] = f.mmapData[start:end:end]
+			}
+		}
+		return compressedTilesData, nil
+	}
+
+	for tileIndex, key := range keys {
+		firstChunkIndex, endChunkIndex := f.chunkIndexes(key)
+		compressedTilesData[tileIndex] = make([][]byte, endChunkIndex-firstChunkIndex)
+		for chunkIndex := firstChunkIndex; chunkIndex < endChunkIndex; chunkIndex++ {
+			if f.chunkByteCounts[chunkIndex] == 0 {
+				continue
+			}
+			chunks = append(chunks, chunkStruct{
+				tileIndex:  tileIndex,
+				chunkIndex: chunkIndex - firstChunkIndex,
+				offset:     f.chunkOffsets[chunkIndex],
+				byteCount:  f.chunkByteCounts[chunkIndex],
+			})
+		}
+	}
+	slices.SortFunc(chunks, func(a, b chunkStruct) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	for len(chunks) > 0 {
+		// Find the run of chunks that are adjacent to each other.
+		n := 1
+		end := chunks[0].offset + chunks[0].byteCount
+		for n < len(chunks) && chunks[n].offset == end && end-chunks[0].offset < maxReadSize {
+			end += chunks[n].byteCount
+			n++
+		}
+
+		data := make([]byte, end-chunks[0].offset)
+		if err := f.readAt(ctx, data, chunks[0].offset); err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks[:n] {
+			compressedTilesData[chunk.tileIndex][chunk.chunkIndex], data = data[:chunk.byteCount:chunk.byteCount], data[chunk.byteCount:]
+		}
+		chunks = chunks[n:]
+	}
+
+	return compressedTilesData, nil
+}
+
+// isEmptyTile returns whether all of compressedChunks are known to be empty,
+// either because they are sparse or because they are f's empty chunk.
+func (f *GeoTIFFTile) isEmptyTile(compressedChunks [][]byte) bool {
+	for _, compressedChunk := range compressedChunks {
+		if len(compressedChunk) != 0 && (f.emptyChunkBytes == nil || !bytes.Equal(compressedChunk, f.emptyChunkBytes)) {
+			return false
+		}
+	}
+	return true
+}
+
+// readAt reads len(data) bytes from f at offset.
+func (f *GeoTIFFTile) readAt(ctx context.Context, data []byte, offset uint64) error {
+	switch n, err := readAtContext(ctx, f.readerAt, data, int64(offset)); {
+	case n == len(data):
+		return nil
 	case err != nil:
-		return nil, err
-	case n != int(tileByteCount):
-		return nil, errShortRead
-	case f.emptyTileBytes != nil && bytes.Equal(compressedData, f.emptyTileBytes):
-		return nil, otter.ErrNotFound
+		return err
 	default:
-		return compressedData, nil
+		return errShortRead
 	}
 }
 
-// decompressTileData decompresses the tile data in compressedData.
-func (f *GeoTIFFTile) decompressTileData(compressedData []byte) ([]byte, error) {
+// decompressTileData decompresses the compressed chunks of the tile with key.
+// Sparse chunks, which are not stored in the file, are left as zeros.
+func (f *GeoTIFFTile) decompressTileData(key tileKey, compressedChunks [][]byte) ([]byte, error) {
+	firstChunkIndex, _ := f.chunkIndexes(key)
 	tileData := make([]byte, f.tileByteCountUncompressed)
-	r := lzw.NewReader(bytes.NewReader(compressedData), lzw.MSB, 8)
-	for bytesRead := 0; bytesRead < f.tileByteCountUncompressed; {
-		n, err := r.Read(tileData[bytesRead:])
-		if err != nil {
-			return nil, err
+	chunkData := tileData
+	for i, compressedChunk := range compressedChunks {
+		chunkByteCount := f.chunkByteCountUncompressed(firstChunkIndex + i)
+		if len(compressedChunk) != 0 {
+			if err := f.decompressFunc(chunkData[:chunkByteCount], compressedChunk); err != nil {
+				return nil, err
+			}
 		}
-		bytesRead += n
+		chunkData = chunkData[chunkByteCount:]
 	}
 	return tileData, nil
 }
 
-// decodeTileData decodes tileData.
-func (f *GeoTIFFTile) decodeTileData(tileData []byte) []float32 {
-	tileSamples := make([]float32, f.tileSampleCount)
-	for i := range f.tileSampleCount {
-		b := binary.LittleEndian.Uint32(tileData[i*4 : (i+1)*4])
-		tileSamples[i] = math.Float32frombits(b)
+// fillSparseChunks sets the samples of the sparse chunks of the tile with key
+// in tileSamples to nodata.
+func (f *GeoTIFFTile) fillSparseChunks(key tileKey, compressedChunks [][]byte, tileSamples []byte) {
+	firstChunkIndex, _ := f.chunkIndexes(key)
+	chunkSamples := tileSamples
+	for i, compressedChunk := range compressedChunks {
+		chunkByteCount := f.chunkByteCountUncompressed(firstChunkIndex + i)
+		if len(compressedChunk) == 0 {
+			for j := 0; j < chunkByteCount; j += len(f.noDataBytes) {
+				copy(chunkSamples[j:], f.noDataBytes)
+			}
+		}
+		chunkSamples = chunkSamples[chunkByteCount:]
+	}
+}
+
+// undoPredictor undoes f's predictor on tileData in place.
+func (f *GeoTIFFTile) undoPredictor(tileData []byte) error {
+	switch f.predictor {
+	case predictorHorizontal:
+		return undoHorizontalDifferencing(tileData, f.tileWidth, f.samplesPerChunkPixel(), f.bytesPerSample, f.byteOrder)
+	case predictorFloatingPoint:
+		return undoFloatingPointPredictor(tileData, f.tileWidth, f.samplesPerChunkPixel(), f.bytesPerSample, f.byteOrder)
+	default:
+		return nil
 	}
-	return tileSamples
 }
 
 // localCoord returns the local coordinate of coord.
 func (t *GeoTIFFTile) localCoord(coord Coord) Coord {
+	if !t.integerGeoreferencing {
+		return t.localCoordFloat64(Float64Coord{X: float64(coord.X), Y: float64(coord.Y)})
+	}
 	return Coord{
-		X: (coord.X - t.translateX) / t.scaleX,
-		Y: -(coord.Y - t.translateY) / t.scaleY,
+		X: floorDiv(coord.X-t.translateX, t.scaleX),
+		Y: floorDiv(t.translateY-coord.Y, t.scaleY),
+	}
+}
+
+// localCoordFloat64 returns the local coordinate of the pixel containing
+// coord. Coordinates outside f are mapped to an invalid local coordinate.
+func (f *GeoTIFFTile) localCoordFloat64(coord Float64Coord) Coord {
+	x, y := f.inverseTransform.apply(coord.X, coord.Y)
+	x, y = math.Floor(x), math.Floor(y)
+	if !(0 <= x && x < float64(f.imageWidth) && 0 <= y && y < float64(f.imageLength)) {
+		return Coord{X: -1, Y: -1}
 	}
+	return Coord{X: int(x), Y: int(y)}
 }
 
-// getTileSamples returns the tile samples at localTileCoord.
-func (f *GeoTIFFTile) getTileSamples(ctx context.Context, localTileCoord TileCoord) ([]float32, error) {
-	// Retrieve the compressed tile data.
-	compressedTileData, err := f.getCompressedTileData(localTileCoord)
+// getTileSamples returns the tile samples of the tile with key, encoded as
+// they are in the file. Empty tiles have no samples.
+func (f *GeoTIFFTile) getTileSamples(ctx context.Context, key tileKey) ([]byte, error) {
+	tilesSamples, err := f.getTilesSamples(ctx, []tileKey{key})
 	if err != nil {
 		return nil, err
 	}
+	return tilesSamples[key], nil
+}
 
-	// Decompress the tile data and decode it.
-	tileData, err := f.decompressTileData(compressedTileData)
+// getTilesSamples returns the tile samples of each of the tiles with keys,
+// encoded as they are in the file. Empty tiles have no samples, and are cached
+// like any other tile so that they are not read again.
+func (f *GeoTIFFTile) getTilesSamples(ctx context.Context, keys []tileKey) (map[tileKey][]byte, error) {
+	compressedTilesData, err := f.readCompressedTilesData(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
-	tileSamples := f.decodeTileData(tileData)
-
-	// If we do not know what an empty tile looks like compressed, check to see
-	// if this is an empty tile, and, if so, use its bytes to detect empty tiles
-	// before they are decompressed. We assume that the empty tile is the
-	// smallest tile.
-	if f.emptyTileBytes == nil && len(compressedTileData) == int(f.smallestTileByteCount) {
-		isEmptyTile := true
-		for _, sample := range tileSamples {
-			if sample != noData {
-				isEmptyTile = false
-				break
-			}
-		}
-		if isEmptyTile {
-			f.emptyTileBytes = compressedTileData
-			return nil, otter.ErrNotFound
+	tilesSamples := make(map[tileKey][]byte, len(keys))
+	for i, key := range keys {
+		switch tileSamples, err := f.decodeTileSamples(key, compressedTilesData[i]); {
+		case errors.Is(err, otter.ErrNotFound):
+			tilesSamples[key] = []byte{}
+		case err != nil:
+			return nil, err
+		default:
+			tilesSamples[key] = tileSamples
 		}
 	}
+	return tilesSamples, nil
+}
+
+// decodeTileSamples returns the tile samples of the tile with key from its
+// compressed chunks. If the tile is empty, it returns the error
+// otter.ErrNotFound. Sparse chunks, which have no compressed data, contain
+// only nodata, or zeros if f has no nodata value.
+func (f *GeoTIFFTile) decodeTileSamples(key tileKey, compressedChunks [][]byte) ([]byte, error) {
+	if f.hasNoData && f.isEmptyTile(compressedChunks) {
+		return nil, otter.ErrNotFound
+	}
+
+	// Decompress the tile data and undo any predictor.
+	tileSamples, err := f.decompressTileData(key, compressedChunks)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.undoPredictor(tileSamples); err != nil {
+		return nil, err
+	}
+	if f.hasNoData {
+		f.fillSparseChunks(key, compressedChunks, tileSamples)
+	}
 
 	return tileSamples, nil
 }
 
-// tileSamplesCache returns the tile at localTileCoord using f's cache.
-func (f *GeoTIFFTile) getTileSamplesCached(ctx context.Context, localTileCoord TileCoord) ([]float32, error) {
-	return f.tileSamplesCache.Get(ctx, localTileCoord, otter.LoaderFunc[TileCoord, []float32](f.getTileSamples))
+// allNoData returns whether all samples are nodata.
+func (f *GeoTIFFTile) allNoData(samples []byte) bool {
+	for i := range len(samples) / f.bytesPerSample {
+		if !f.isNoData(f.sampleFunc(samples, i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// getTileSamplesCached returns the tile samples of the tile with key using
+// f's cache.
+func (f *GeoTIFFTile) getTileSamplesCached(ctx context.Context, key tileKey) ([]byte, error) {
+	return f.tileSamplesCache.Get(ctx, key, otter.LoaderFunc[tileKey, []byte](f.getTileSamples))
 }
 
 // localTileCoord returns the local tile coord for a given coordinate.
@@ -349,11 +1276,132 @@ func (f *GeoTIFFTile) localTileCoord(localCoord Coord) (TileCoord, bool) {
 	}, true
 }
 
-// tileSample returns the sample from tileSamples at localCoord.
-func (f *GeoTIFFTile) tileSample(tileSamples []float32, localCoord Coord) float64 {
-	sample := tileSamples[localCoord.X%f.tileWidth+(localCoord.Y%f.tileLength)*f.tileWidth]
-	if sample == noData {
+// tileSampleIndex returns the index of the sample of band at localCoord in its
+// tile's samples.
+func (f *GeoTIFFTile) tileSampleIndex(localCoord Coord, band int) int {
+	pixelIndex := localCoord.X%f.tileWidth + (localCoord.Y%f.tileLength)*f.tileWidth
+	if f.planar {
+		return pixelIndex
+	}
+	return pixelIndex*f.samplesPerPixel + band
+}
+
+// tileSample returns the sample of band from tileSamples at localCoord.
+func (f *GeoTIFFTile) tileSample(tileSamples []byte, localCoord Coord, band int) float64 {
+	sample := f.sampleFunc(tileSamples, f.tileSampleIndex(localCoord, band))
+	if f.isNoData(sample) {
 		return math.NaN()
 	}
-	return float64(sample)
+	return f.scaleOffset(sample, band)
+}
+
+// readSample reads the sample of band at localCoord in the tile with key
+// directly from f's file, which must be uncompressed.
+func (f *GeoTIFFTile) readSample(ctx context.Context, key tileKey, localCoord Coord, band int) (float64, error) {
+	firstChunkIndex, _ := f.chunkIndexes(key)
+	chunkByteCountUncompressed := f.tileByteCountUncompressed / f.chunksPerTile
+	byteOffset := f.tileSampleIndex(localCoord, band) * f.bytesPerSample
+	chunkIndex := firstChunkIndex + byteOffset/chunkByteCountUncompressed
+	chunkByteOffset := uint64(byteOffset % chunkByteCountUncompressed)
+	if f.chunkByteCounts[chunkIndex] == 0 {
+		if f.hasNoData {
+			return math.NaN(), nil
+		}
+		return f.scaleOffset(0, band), nil
+	}
+	if chunkByteOffset+uint64(f.bytesPerSample) > f.chunkByteCounts[chunkIndex] {
+		return 0, errShortRead
+	}
+	offset := f.chunkOffsets[chunkIndex] + chunkByteOffset
+
+	var data []byte
+	if f.mmapData != nil {
+		if offset+uint64(f.bytesPerSample) > uint64(len(f.mmapData)) {
+			return 0, errShortRead
+		}
+		data = f.mmapData[offset:]
+	} else {
+		var buf [8]byte
+		data = buf[:f.bytesPerSample]
+		if err := f.readAt(ctx, data, offset); err != nil {
+			return 0, err
+		}
+	}
+
+	sample := f.sampleFunc(data, 0)
+	if f.isNoData(sample) {
+		return math.NaN(), nil
+	}
+	return f.scaleOffset(sample, band), nil
+}
+
+// scaleOffset returns sample from band with the scale and offset from f's GDAL
+// metadata applied, if enabled.
+func (f *GeoTIFFTile) scaleOffset(sample float64, band int) float64 {
+	if !f.gdalScaleOffset || f.gdalMetadata == nil {
+		return sample
+	}
+	return f.gdalMetadata.scaleOffset(sample, band)
+}
+
+// isNoData returns whether sample is f's nodata value.
+func (f *GeoTIFFTile) isNoData(sample float64) bool {
+	switch {
+	case !f.hasNoData:
+		return false
+	case math.IsNaN(f.noData):
+		return math.IsNaN(sample)
+	default:
+		return sample == f.noData
+	}
+}
+
+// parseGDALNoData parses the value of a GDAL_NODATA tag. GDAL writes the
+// nodata value as text using the C library's formatting, so NaNs and
+// infinities can be spelled in several different ways.
+func parseGDALNoData(s string) (float64, error) {
+	s = strings.TrimSpace(strings.TrimRight(s, "\x00"))
+	switch strings.ToLower(s) {
+	case "nan", "-nan", "+nan", "1.#qnan", "-1.#qnan", "1.#snan", "-1.#snan", "-1.#ind", "1.#ind":
+		return math.NaN(), nil
+	case "1.#inf", "+1.#inf":
+		return math.Inf(1), nil
+	case "-1.#inf":
+		return math.Inf(-1), nil
+	}
+	noData, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("GDAL_NODATA %q: %w", s, err)
+	}
+	return noData, nil
+}
+
+// uniformSampleValue returns the value of a per-sample field, such as
+// BitsPerSample, which must be the same for all samplesPerPixel samples. A
+// single value applies to all samples, and a missing value is defaultValue.
+func uniformSampleValue(values []uint16, samplesPerPixel, defaultValue int) (int, error) {
+	switch {
+	case len(values) == 0:
+		return defaultValue, nil
+	case len(values) != 1 && len(values) != samplesPerPixel:
+		return 0, fmt.Errorf("%d values for %d samples per pixel", len(values), samplesPerPixel)
+	case slices.ContainsFunc(values, func(value uint16) bool { return value != values[0] }):
+		return 0, fmt.Errorf("%v: different values for each sample: %w", values, errors.ErrUnsupported)
+	default:
+		return int(values[0]), nil
+	}
+}
+
+// isInt returns whether x is an integer that fits in an int.
+func isInt(x float64) bool {
+	return x == math.Trunc(x) && math.MinInt32 <= x && x <= math.MaxInt32
+}
+
+// floorDiv returns a divided by b, rounded towards negative infinity. b must be
+// positive.
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
 }
@@ -1,15 +1,24 @@
 package elevation
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"math"
 	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"testing"
+	"testing/fstest"
 
 	"github.com/alecthomas/assert/v2"
-	"github.com/maypok86/otter/v2"
 )
 
 func TestNewGeoTIFFTile(t *testing.T) {
@@ -112,13 +121,1621 @@ func TestGeoTIFFFile_Pixel(t *testing.T) {
 	}
 }
 
+func TestGeoTIFFTile_Compression(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		compression uint16
+		compress    func([]byte) []byte
+	}{
+		{
+			name:        "none",
+			compression: CompressionNone,
+			compress:    slices.Clone[[]byte],
+		},
+		{
+			name:        "adobe_deflate",
+			compression: CompressionAdobeDeflate,
+			compress:    mustCompress(compressDeflate),
+		},
+		{
+			name:        "packbits",
+			compression: CompressionPackBits,
+			compress:    compressPackBits,
+		},
+		{
+			name:        "deflate",
+			compression: CompressionDeflate,
+			compress:    mustCompress(compressDeflate),
+		},
+		{
+			name:        "lerc",
+			compression: CompressionLERC,
+			compress:    compressLERCFloat32,
+		},
+		{
+			name:        "lerc_deflate",
+			compression: CompressionLERC,
+			compress: func(data []byte) []byte {
+				return mustCompress(compressDeflate)(compressLERCFloat32(data))
+			},
+		},
+		{
+			name:        "lerc_zstd",
+			compression: CompressionLERC,
+			compress: func(data []byte) []byte {
+				return mustCompress(compressZSTD)(compressLERCFloat32(data))
+			},
+		},
+		{
+			name:        "zstd",
+			compression: CompressionZSTD,
+			compress:    mustCompress(compressZSTD),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.compression = tc.compression
+			g.compress = tc.compress
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			visitAllTiles(t, geoTIFFTile)
+			// Empty uncompressed chunks cannot be detected by their size.
+			assert.Equal(t, tc.compression != CompressionNone, geoTIFFTile.emptyChunkBytes != nil)
+			testSampleSamplesEquivalence(t, geoTIFFTile)
+		})
+	}
+}
+
+func TestGeoTIFFTile_Predictor(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		predictor uint16
+	}{
+		{
+			name:      "horizontal",
+			predictor: predictorHorizontal,
+		},
+		{
+			name:      "floating_point",
+			predictor: predictorFloatingPoint,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.predictor = tc.predictor
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			testSampleSamplesEquivalence(t, geoTIFFTile)
+		})
+	}
+}
+
+func TestGeoTIFFTile_SampleFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		sampleFormat  uint16
+		bitsPerSample uint16
+		noData        string
+		sample        func(x, y int) float64
+		predictors    []uint16
+	}{
+		{
+			name:          "uint8",
+			sampleFormat:  sampleFormatUint,
+			bitsPerSample: 8,
+			noData:        "255",
+			sample: func(x, y int) float64 {
+				return float64((x + y) % 255)
+			},
+		},
+		{
+			name:          "int8",
+			sampleFormat:  sampleFormatInt,
+			bitsPerSample: 8,
+			noData:        "-128",
+			sample: func(x, y int) float64 {
+				return float64((x+y)%255 - 127)
+			},
+		},
+		{
+			name:          "uint16",
+			sampleFormat:  sampleFormatUint,
+			bitsPerSample: 16,
+			noData:        "65535",
+			sample: func(x, y int) float64 {
+				return float64(x + 100*y)
+			},
+		},
+		{
+			name:          "int16",
+			sampleFormat:  sampleFormatInt,
+			bitsPerSample: 16,
+			noData:        "-32768",
+			sample: func(x, y int) float64 {
+				return float64(x - 100*y)
+			},
+		},
+		{
+			name:          "uint32",
+			sampleFormat:  sampleFormatUint,
+			bitsPerSample: 32,
+			noData:        "0",
+			sample: func(x, y int) float64 {
+				return float64(1 + x + 100000*y)
+			},
+		},
+		{
+			name:          "int32",
+			sampleFormat:  sampleFormatInt,
+			bitsPerSample: 32,
+			noData:        "-9999",
+			sample: func(x, y int) float64 {
+				return float64(x - 100000*y)
+			},
+		},
+		{
+			name:          "float32",
+			sampleFormat:  sampleFormatIEEEFP,
+			bitsPerSample: 32,
+			noData:        "-3.4028234663852886e+038",
+			sample: func(x, y int) float64 {
+				return float64(float32(x) + float32(y)/8)
+			},
+			predictors: []uint16{predictorFloatingPoint},
+		},
+		{
+			name:          "float64",
+			sampleFormat:  sampleFormatIEEEFP,
+			bitsPerSample: 64,
+			noData:        "-1e+300",
+			sample: func(x, y int) float64 {
+				return float64(x) + float64(y)/3
+			},
+			predictors: []uint16{predictorFloatingPoint},
+		},
+	} {
+		for _, byteOrder := range []testByteOrder{binary.LittleEndian, binary.BigEndian} {
+			for _, predictor := range append([]uint16{predictorNone, predictorHorizontal}, tc.predictors...) {
+				t.Run(tc.name+"_"+byteOrder.String()+"_"+strconv.Itoa(int(predictor)), func(t *testing.T) {
+					g := newTestGeoTIFF()
+					g.byteOrder = byteOrder
+					g.sampleFormat = tc.sampleFormat
+					g.bitsPerSample = tc.bitsPerSample
+					g.noDataSample, _ = strconv.ParseFloat(tc.noData, 64)
+					g.noData = tc.noData
+					g.sample = tc.sample
+					g.predictor = predictor
+					geoTIFFTile := newTestGeoTIFFTile(t, g)
+					assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+					visitAllTiles(t, geoTIFFTile)
+					assert.NotZero(t, geoTIFFTile.emptyChunkBytes)
+				})
+			}
+		}
+	}
+}
+
+func TestGeoTIFFTile_NoData(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		sampleFormat      uint16
+		bitsPerSample     uint16
+		noDataSample      float64
+		noData            string
+		options           []GeoTIFFTileOption
+		isNoData          func(x, y int) bool
+		expectedHasNoData bool
+		expectedNoData    float64
+		expectedEmpty     bool
+	}{
+		{
+			name:              "float32_nan",
+			sampleFormat:      sampleFormatIEEEFP,
+			bitsPerSample:     32,
+			noDataSample:      math.NaN(),
+			noData:            "nan",
+			expectedHasNoData: true,
+			expectedNoData:    math.NaN(),
+			expectedEmpty:     true,
+		},
+		{
+			name:              "float32_integer",
+			sampleFormat:      sampleFormatIEEEFP,
+			bitsPerSample:     32,
+			noDataSample:      -9999,
+			noData:            "-9999",
+			expectedHasNoData: true,
+			expectedNoData:    -9999,
+			expectedEmpty:     true,
+		},
+		{
+			name:              "int16_option",
+			sampleFormat:      sampleFormatInt,
+			bitsPerSample:     16,
+			noDataSample:      -9999,
+			options:           []GeoTIFFTileOption{WithNoData(-9999)},
+			expectedHasNoData: true,
+			expectedNoData:    -9999,
+			expectedEmpty:     true,
+		},
+		{
+			name:              "int16_option_override",
+			sampleFormat:      sampleFormatInt,
+			bitsPerSample:     16,
+			noDataSample:      -9999,
+			noData:            "-32768",
+			options:           []GeoTIFFTileOption{WithNoData(-9999)},
+			expectedHasNoData: true,
+			expectedNoData:    -9999,
+			expectedEmpty:     true,
+		},
+		{
+			name:          "int16_missing",
+			sampleFormat:  sampleFormatInt,
+			bitsPerSample: 16,
+			isNoData: func(x, y int) bool {
+				return false
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.sampleFormat = tc.sampleFormat
+			g.bitsPerSample = tc.bitsPerSample
+			g.noDataSample = tc.noDataSample
+			g.noData = tc.noData
+			g.sample = func(x, y int) float64 {
+				return float64(x + 100*y)
+			}
+			if tc.isNoData != nil {
+				g.isNoData = tc.isNoData
+			}
+			geoTIFFTile := newTestGeoTIFFTile(t, g, tc.options...)
+			actualNoData, actualHasNoData := geoTIFFTile.NoData()
+			assert.Equal(t, tc.expectedHasNoData, actualHasNoData)
+			if math.IsNaN(tc.expectedNoData) {
+				assert.True(t, math.IsNaN(actualNoData))
+			} else if tc.expectedHasNoData {
+				assert.Equal(t, tc.expectedNoData, actualNoData)
+			}
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			visitAllTiles(t, geoTIFFTile)
+			assert.Equal(t, tc.expectedEmpty, geoTIFFTile.emptyChunkBytes != nil)
+		})
+	}
+}
+
+func TestParseGDALNoData(t *testing.T) {
+	for _, tc := range []struct {
+		s           string
+		expected    float64
+		expectedErr bool
+	}{
+		{s: "-3.4028234663852886e+038", expected: -math.MaxFloat32},
+		{s: "-3.40282346638529e+38", expected: -3.40282346638529e+38},
+		{s: "-9999", expected: -9999},
+		{s: " -32768 ", expected: -32768},
+		{s: "0\x00", expected: 0},
+		{s: "1e+038", expected: 1e38},
+		{s: "nan", expected: math.NaN()},
+		{s: "NaN", expected: math.NaN()},
+		{s: "-nan", expected: math.NaN()},
+		{s: "1.#QNAN", expected: math.NaN()},
+		{s: "inf", expected: math.Inf(1)},
+		{s: "-inf", expected: math.Inf(-1)},
+		{s: "-1.#INF", expected: math.Inf(-1)},
+		{s: "none", expectedErr: true},
+	} {
+		t.Run(tc.s, func(t *testing.T) {
+			actual, err := parseGDALNoData(tc.s)
+			switch {
+			case tc.expectedErr:
+				assert.Error(t, err)
+			case math.IsNaN(tc.expected):
+				assert.NoError(t, err)
+				assert.True(t, math.IsNaN(actual))
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_Sparse(t *testing.T) {
+	// Sparse files omit chunks whose samples are all nodata, or all zero if
+	// there is no nodata value.
+	noNoData := func(g *testGeoTIFF) {
+		sample := g.sample
+		g.sample = func(x, y int) float64 {
+			if x >= 256 && y >= 128 {
+				return 0
+			}
+			return sample(x, y)
+		}
+		g.isNoData = func(x, y int) bool {
+			return false
+		}
+		g.noDataSample = 0
+		g.noData = ""
+	}
+	uncompressed := func(g *testGeoTIFF) {
+		g.compression = CompressionNone
+		g.compress = slices.Clone[[]byte]
+	}
+	strips := func(g *testGeoTIFF) {
+		g.tileWidth = 0
+		g.tileLength = 0
+		g.rowsPerStrip = 10
+		g.isNoData = func(x, y int) bool {
+			return y >= 155
+		}
+	}
+	for _, tc := range []struct {
+		name                  string
+		modifyFuncs           []func(*testGeoTIFF)
+		options               []GeoTIFFTileOption
+		expectedDirectSamples bool
+	}{
+		{
+			name: "tiles",
+		},
+		{
+			name:        "tiles_no_nodata",
+			modifyFuncs: []func(*testGeoTIFF){noNoData},
+		},
+		{
+			name:                  "tiles_direct",
+			modifyFuncs:           []func(*testGeoTIFF){uncompressed},
+			options:               []GeoTIFFTileOption{WithDirectSampleReads(true)},
+			expectedDirectSamples: true,
+		},
+		{
+			name:                  "tiles_direct_no_nodata",
+			modifyFuncs:           []func(*testGeoTIFF){uncompressed, noNoData},
+			options:               []GeoTIFFTileOption{WithDirectSampleReads(true)},
+			expectedDirectSamples: true,
+		},
+		{
+			name:    "tiles_mmap",
+			options: []GeoTIFFTileOption{WithMmap(true)},
+		},
+		{
+			name:        "strips",
+			modifyFuncs: []func(*testGeoTIFF){strips},
+		},
+		{
+			name:        "strips_predictor",
+			modifyFuncs: []func(*testGeoTIFF){strips, func(g *testGeoTIFF) { g.predictor = predictorFloatingPoint }},
+		},
+		{
+			name:                  "strips_direct",
+			modifyFuncs:           []func(*testGeoTIFF){strips, uncompressed},
+			options:               []GeoTIFFTileOption{WithDirectSampleReads(true)},
+			expectedDirectSamples: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.sparse = true
+			for _, modifyFunc := range tc.modifyFuncs {
+				modifyFunc(&g)
+			}
+			geoTIFFTile := newTestGeoTIFFTile(t, g, tc.options...)
+			assert.Equal(t, tc.expectedDirectSamples, geoTIFFTile.directSamples)
+			assert.True(t, slices.Contains(geoTIFFTile.chunkByteCounts, 0))
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			visitAllTiles(t, geoTIFFTile)
+			testSampleSamplesEquivalence(t, geoTIFFTile)
+		})
+	}
+}
+
+func TestGeoTIFFTile_Strips(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		rowsPerStrip          int
+		stripGroupSizeBytes   int
+		expectedChunksPerTile int
+		expectedTilesDown     int
+	}{
+		{
+			name:                  "single_strip",
+			rowsPerStrip:          200,
+			expectedChunksPerTile: 1,
+			expectedTilesDown:     1,
+		},
+		{
+			name:                  "partial_last_strip",
+			rowsPerStrip:          7,
+			stripGroupSizeBytes:   1,
+			expectedChunksPerTile: 1,
+			expectedTilesDown:     29,
+		},
+		{
+			name:                  "grouped_strips",
+			rowsPerStrip:          1,
+			stripGroupSizeBytes:   16 * 300 * 4,
+			expectedChunksPerTile: 16,
+			expectedTilesDown:     13,
+		},
+		{
+			name:                  "grouped_partial_last_strip",
+			rowsPerStrip:          3,
+			stripGroupSizeBytes:   4 * 3 * 300 * 4,
+			expectedChunksPerTile: 4,
+			expectedTilesDown:     17,
+		},
+	} {
+		for _, predictor := range []uint16{predictorNone, predictorFloatingPoint} {
+			t.Run(tc.name+"_"+strconv.Itoa(int(predictor)), func(t *testing.T) {
+				g := newTestGeoTIFF()
+				g.tileWidth = 0
+				g.tileLength = 0
+				g.rowsPerStrip = tc.rowsPerStrip
+				g.predictor = predictor
+				g.isNoData = func(x, y int) bool {
+					return y >= 150
+				}
+				var options []GeoTIFFTileOption
+				if tc.stripGroupSizeBytes != 0 {
+					options = append(options, func(f *GeoTIFFTile) {
+						f.stripGroupSizeBytes = tc.stripGroupSizeBytes
+					})
+				}
+				geoTIFFTile := newTestGeoTIFFTile(t, g, options...)
+				assert.Equal(t, tc.expectedChunksPerTile, geoTIFFTile.chunksPerTile)
+				assert.Equal(t, tc.expectedTilesDown, geoTIFFTile.tilesDown)
+				assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+				visitAllTiles(t, geoTIFFTile)
+				testSampleSamplesEquivalence(t, geoTIFFTile)
+			})
+		}
+	}
+}
+
+func TestGeoTIFFTile_Georeferencing(t *testing.T) {
+	for _, tc := range []struct {
+		name                          string
+		pixelScale                    []float64
+		tiepoint                      []float64
+		expectedIntegerGeoreferencing bool
+		expectedOrigin                Float64Coord
+	}{
+		{
+			name:                          "integer",
+			pixelScale:                    []float64{10, 10, 0},
+			tiepoint:                      []float64{0, 0, 0, 1000, 2000, 0},
+			expectedIntegerGeoreferencing: true,
+			expectedOrigin:                Float64Coord{X: 1000, Y: 2000},
+		},
+		{
+			name:                          "integer_offset_tiepoint",
+			pixelScale:                    []float64{10, 10, 0},
+			tiepoint:                      []float64{2, 3, 0, 1020, 1970, 0},
+			expectedIntegerGeoreferencing: true,
+			expectedOrigin:                Float64Coord{X: 1000, Y: 2000},
+		},
+		{
+			name:                          "half_meter",
+			pixelScale:                    []float64{0.5, 0.5, 0},
+			tiepoint:                      []float64{0, 0, 0, 1000, 2000, 0},
+			expectedIntegerGeoreferencing: false,
+			expectedOrigin:                Float64Coord{X: 1000, Y: 2000},
+		},
+		{
+			name:                          "arc_second",
+			pixelScale:                    []float64{1.0 / 3600, 1.0 / 3600, 0},
+			tiepoint:                      []float64{0, 0, 0, 6 - 0.5/3600, 47 + 0.5/3600, 0},
+			expectedIntegerGeoreferencing: false,
+			expectedOrigin:                Float64Coord{X: 6 - 0.5/3600, Y: 47 + 0.5/3600},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.pixelScale = tc.pixelScale
+			g.tiepoint = tc.tiepoint
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+			assert.Equal(t, tc.expectedIntegerGeoreferencing, geoTIFFTile.integerGeoreferencing)
+			assert.Equal(t, tc.expectedOrigin, Float64Coord{X: geoTIFFTile.transform.c, Y: geoTIFFTile.transform.f})
+			pixelScaleX, pixelScaleY := geoTIFFTile.PixelScale()
+			assert.Equal(t, tc.pixelScale[:2], []float64{pixelScaleX, pixelScaleY})
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+
+			// Sample just inside and just outside each corner of the image.
+			originX, originY := geoTIFFTile.transform.c, geoTIFFTile.transform.f
+			width := float64(g.imageWidth) * pixelScaleX
+			length := float64(g.imageLength) * pixelScaleY
+			epsilon := pixelScaleX / 16
+			for _, tc2 := range []struct {
+				coord    Float64Coord
+				expected float64
+			}{
+				{Float64Coord{X: originX + epsilon, Y: originY - epsilon}, g.sample(0, 0)},
+				{Float64Coord{X: originX - epsilon, Y: originY - epsilon}, math.NaN()},
+				{Float64Coord{X: originX + epsilon, Y: originY + epsilon}, math.NaN()},
+				{Float64Coord{X: originX + width - epsilon, Y: originY - epsilon}, g.sample(g.imageWidth-1, 0)},
+				{Float64Coord{X: originX + width + epsilon, Y: originY - epsilon}, math.NaN()},
+				{Float64Coord{X: originX + epsilon, Y: originY - length + epsilon}, g.sample(0, g.imageLength-1)},
+				{Float64Coord{X: originX + epsilon, Y: originY - length - epsilon}, math.NaN()},
+				{Float64Coord{X: math.NaN(), Y: math.NaN()}, math.NaN()},
+				{Float64Coord{X: math.Inf(1), Y: math.Inf(-1)}, math.NaN()},
+			} {
+				actual, err := geoTIFFTile.SampleFloat64(t.Context(), tc2.coord)
+				assert.NoError(t, err)
+				assert.Equal(t, tc2.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_ModelTransformation(t *testing.T) {
+	sin, cos := math.Sincos(math.Pi / 6)
+	for _, tc := range []struct {
+		name                          string
+		transform                     []float64
+		expectedIntegerGeoreferencing bool
+		expectedErr                   bool
+	}{
+		{
+			name: "north_up",
+			transform: []float64{
+				10, 0, 0, 1000,
+				0, -10, 0, 2000,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			},
+			expectedIntegerGeoreferencing: true,
+		},
+		{
+			name: "rotated",
+			transform: []float64{
+				2 * cos, -2 * sin, 0, 1000,
+				-2 * sin, -2 * cos, 0, 2000,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			},
+		},
+		{
+			name: "sheared",
+			transform: []float64{
+				0.5, 0.25, 0, 6,
+				0.125, -0.5, 0, 47,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			},
+		},
+		{
+			name: "south_up",
+			transform: []float64{
+				10, 0, 0, 1000,
+				0, 10, 0, 2000,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			},
+		},
+		{
+			name: "singular",
+			transform: []float64{
+				1, 2, 0, 1000,
+				2, 4, 0, 2000,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+			},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.transform = tc.transform
+			if tc.expectedErr {
+				dir := t.TempDir()
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "test.tif"), g.bytes(), 0o666))
+				_, err := NewGeoTIFFTile(os.DirFS(dir), "test.tif")
+				assert.Error(t, err)
+				return
+			}
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+			assert.Equal(t, tc.expectedIntegerGeoreferencing, geoTIFFTile.integerGeoreferencing)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+		})
+	}
+}
+
+func TestGeoTIFFTile_RasterType(t *testing.T) {
+	// The expected pixels follow GDAL's conventions: the tie point of a
+	// PixelIsArea raster is the top left corner of the top left pixel, and
+	// the tie point of a PixelIsPoint raster is the center of the top left
+	// pixel. With a tie point at 1000, 2000 and a pixel scale of 10, GDAL
+	// reports an origin of 1000, 2000 for PixelIsArea and 995, 2005 for
+	// PixelIsPoint.
+	type pixel struct {
+		x, y int
+	}
+	outside := pixel{x: -1, y: -1}
+	for _, tc := range []struct {
+		name               string
+		geoKeys            []uint16
+		expectedRasterType int
+		expected           map[Float64Coord]pixel
+	}{
+		{
+			name:               "none",
+			expectedRasterType: RasterPixelIsArea,
+			expected: map[Float64Coord]pixel{
+				{X: 1000, Y: 2000}:         {x: 0, y: 0},
+				{X: 1009.5, Y: 1990.5}:     {x: 0, y: 0},
+				{X: 1010, Y: 1990}:         {x: 1, y: 1},
+				{X: 999.5, Y: 1999.5}:      outside,
+				{X: 3999.5, Y: 0.5}:        {x: 299, y: 199},
+				{X: 4000, Y: 0}:            outside,
+				{X: 1005, Y: 2000 - 12345}: outside,
+			},
+		},
+		{
+			name: "pixel_is_area",
+			geoKeys: []uint16{
+				1, 1, 0, 2,
+				1024, 0, 1, 1,
+				1025, 0, 1, RasterPixelIsArea,
+			},
+			expectedRasterType: RasterPixelIsArea,
+			expected: map[Float64Coord]pixel{
+				{X: 1000, Y: 2000}:     {x: 0, y: 0},
+				{X: 1009.5, Y: 1990.5}: {x: 0, y: 0},
+				{X: 1010, Y: 1990}:     {x: 1, y: 1},
+				{X: 999.5, Y: 1999.5}:  outside,
+			},
+		},
+		{
+			name: "pixel_is_point",
+			geoKeys: []uint16{
+				1, 1, 0, 2,
+				1024, 0, 1, 1,
+				1025, 0, 1, RasterPixelIsPoint,
+			},
+			expectedRasterType: RasterPixelIsPoint,
+			expected: map[Float64Coord]pixel{
+				{X: 995, Y: 2005}:      {x: 0, y: 0},
+				{X: 1000, Y: 2000}:     {x: 0, y: 0},
+				{X: 1004.5, Y: 1995.5}: {x: 0, y: 0},
+				{X: 1005, Y: 1995}:     {x: 1, y: 1},
+				{X: 994.5, Y: 2004.5}:  outside,
+				{X: 3994.5, Y: 5.5}:    {x: 299, y: 199},
+				{X: 3995, Y: 5}:        outside,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.isNoData = func(x, y int) bool {
+				return false
+			}
+			g.geoKeys = tc.geoKeys
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+			assert.Equal(t, tc.expectedRasterType, geoTIFFTile.RasterType())
+			assert.True(t, geoTIFFTile.integerGeoreferencing)
+
+			for coord, pixel := range tc.expected {
+				expected := math.NaN()
+				if pixel != outside {
+					expected = g.sample(pixel.x, pixel.y)
+				}
+
+				actual, err := geoTIFFTile.SampleFloat64(t.Context(), coord)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, actual, "%v", coord)
+
+				// Check that the integer fast path agrees where the
+				// coordinate is an integer.
+				if coord.X == math.Trunc(coord.X) && coord.Y == math.Trunc(coord.Y) {
+					actual, err := geoTIFFTile.Sample(t.Context(), Coord{X: int(coord.X), Y: int(coord.Y)})
+					assert.NoError(t, err)
+					assert.Equal(t, expected, actual, "%v", coord)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_GDALLocationInfo(t *testing.T) {
+	// The fixtures are 4x3 int16 rasters in EPSG:3035 with a tie point at
+	// 1000, 2000 and a pixel scale of 10, whose samples are 100+10*y+x. The
+	// expected samples follow the conventions of
+	//
+	//	gdallocationinfo -valonly -geoloc testdata/geotiff/<filename> <x> <y>
+	//
+	// which samples the pixel containing x, y after moving the origin of
+	// PixelIsPoint rasters to 995, 2005. Coordinates outside the raster are
+	// reported as NaN. They were derived from these conventions, not by
+	// running gdallocationinfo.
+	for _, tc := range []struct {
+		filename string
+		coords   []Float64Coord
+		expected []float64
+	}{
+		{
+			filename: "pixelisarea.tif",
+			coords: []Float64Coord{
+				{X: 1000, Y: 2000},
+				{X: 1009.99, Y: 1990.01},
+				{X: 1010, Y: 1990},
+				{X: 1025, Y: 1975},
+				{X: 1039.99, Y: 1970.01},
+				{X: 999.99, Y: 2000},
+				{X: 1000, Y: 2000.01},
+				{X: 1040, Y: 1990},
+				{X: 1010, Y: 1970},
+			},
+			expected: []float64{
+				100,
+				100,
+				111,
+				122,
+				123,
+				math.NaN(),
+				math.NaN(),
+				math.NaN(),
+				math.NaN(),
+			},
+		},
+		{
+			filename: "pixelispoint.tif",
+			coords: []Float64Coord{
+				{X: 995, Y: 2005},
+				{X: 1000, Y: 2000},
+				{X: 1004.99, Y: 1995.01},
+				{X: 1005, Y: 1995},
+				{X: 1020, Y: 1980},
+				{X: 1034.99, Y: 1975.01},
+				{X: 994.99, Y: 2000},
+				{X: 1035, Y: 1980},
+				{X: 1010, Y: 1975},
+			},
+			expected: []float64{
+				100,
+				100,
+				100,
+				111,
+				122,
+				123,
+				math.NaN(),
+				math.NaN(),
+				math.NaN(),
+			},
+		},
+	} {
+		t.Run(tc.filename, func(t *testing.T) {
+			geoTIFFTile, err := NewGeoTIFFTile(os.DirFS("testdata/geotiff"), tc.filename)
+			assert.NoError(t, err)
+			defer func() {
+				assert.NoError(t, geoTIFFTile.Close())
+			}()
+
+			actual, err := geoTIFFTile.SamplesFloat64(t.Context(), tc.coords)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+
+			for i, coord := range tc.coords {
+				if coord.X != math.Trunc(coord.X) || coord.Y != math.Trunc(coord.Y) {
+					continue
+				}
+				actual, err := geoTIFFTile.Sample(t.Context(), Coord{X: int(coord.X), Y: int(coord.Y)})
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected[i], actual, "%v", coord)
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_CRS(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		geoKeys             []uint16
+		expectedEPSG        int
+		expectedEPSGOK      bool
+		expectedLinearUnits int
+		expectedUnitsOK     bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "projected",
+			geoKeys: []uint16{
+				1, 1, 0, 4,
+				1024, 0, 1, ModelTypeProjected,
+				1025, 0, 1, RasterPixelIsArea,
+				3072, 0, 1, 3035,
+				3076, 0, 1, LinearUnitMetre,
+			},
+			expectedEPSG:        3035,
+			expectedEPSGOK:      true,
+			expectedLinearUnits: LinearUnitMetre,
+			expectedUnitsOK:     true,
+		},
+		{
+			name: "projected_feet",
+			geoKeys: []uint16{
+				1, 1, 0, 3,
+				1024, 0, 1, ModelTypeProjected,
+				3072, 0, 1, 2229,
+				3076, 0, 1, LinearUnitUSSurveyFoot,
+			},
+			expectedEPSG:        2229,
+			expectedEPSGOK:      true,
+			expectedLinearUnits: LinearUnitUSSurveyFoot,
+			expectedUnitsOK:     true,
+		},
+		{
+			name: "geographic",
+			geoKeys: []uint16{
+				1, 1, 0, 2,
+				1024, 0, 1, ModelTypeGeographic,
+				2048, 0, 1, 4326,
+			},
+			expectedEPSG:   4326,
+			expectedEPSGOK: true,
+		},
+		{
+			name: "user_defined",
+			geoKeys: []uint16{
+				1, 1, 0, 3,
+				1024, 0, 1, ModelTypeProjected,
+				3072, 0, 1, geoKeyUserDefined,
+				3076, 0, 1, LinearUnitFoot,
+			},
+			expectedLinearUnits: LinearUnitFoot,
+			expectedUnitsOK:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.geoKeys = tc.geoKeys
+			geoTIFFTile := newTestGeoTIFFTile(t, g)
+
+			epsg, ok := geoTIFFTile.EPSG()
+			assert.Equal(t, tc.expectedEPSG, epsg)
+			assert.Equal(t, tc.expectedEPSGOK, ok)
+
+			linearUnits, ok := geoTIFFTile.LinearUnits()
+			assert.Equal(t, tc.expectedLinearUnits, linearUnits)
+			assert.Equal(t, tc.expectedUnitsOK, ok)
+		})
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	for _, tc := range []struct {
+		a, b, expected int
+	}{
+		{a: 0, b: 10, expected: 0},
+		{a: 9, b: 10, expected: 0},
+		{a: 10, b: 10, expected: 1},
+		{a: -1, b: 10, expected: -1},
+		{a: -10, b: 10, expected: -1},
+		{a: -11, b: 10, expected: -2},
+	} {
+		assert.Equal(t, tc.expected, floorDiv(tc.a, tc.b))
+	}
+}
+
+func TestGeoTIFFTile_Overviews(t *testing.T) {
+	g := newTestGeoTIFF()
+	g.isNoData = func(x, y int) bool {
+		return x >= 256 && y >= 128
+	}
+
+	// Create overviews by decimating g, like GDAL's nearest neighbour
+	// resampling.
+	newOverview := func(factor int) testGeoTIFF {
+		overview := g
+		overview.newSubfileType = newSubfileTypeReducedResolution
+		overview.imageWidth = g.imageWidth / factor
+		overview.imageLength = g.imageLength / factor
+		overview.tileWidth = 64
+		overview.tileLength = 64
+		overview.pixelScale = nil
+		overview.tiepoint = nil
+		overview.sample = func(x, y int) float64 {
+			return g.sample(factor*x, factor*y)
+		}
+		overview.isNoData = func(x, y int) bool {
+			return g.isNoData(factor*x, factor*y)
+		}
+		return overview
+	}
+	overview2 := newOverview(2)
+	overview4 := newOverview(4)
+	overview4.tileWidth = 0
+	overview4.tileLength = 0
+	overview4.rowsPerStrip = 8
+
+	// Add a mask, which should be ignored.
+	mask := newOverview(1)
+	mask.newSubfileType = 4
+
+	// Write the overviews in the opposite order to make sure that they are
+	// sorted.
+	dir := t.TempDir()
+	data := encodeTestTIFF(g.byteOrder, []testTIFFIFD{g.ifd(), overview4.ifd(), mask.ifd(), overview2.ifd()})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "test.tif"), data, 0o666))
+	geoTIFFTile, err := NewGeoTIFFTile(os.DirFS(dir), "test.tif")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, geoTIFFTile.Close())
+	}()
+
+	assert.Equal(t, 2, len(geoTIFFTile.Overviews()))
+	assert.Equal(t, geoTIFFTile, geoTIFFTile.Level(0))
+	assert.Equal(t, geoTIFFTile.Overviews()[0], geoTIFFTile.Level(1))
+	assert.Equal(t, geoTIFFTile.Overviews()[1], geoTIFFTile.Level(2))
+	assert.Zero(t, geoTIFFTile.Level(3))
+	assert.Zero(t, geoTIFFTile.Level(-1))
+
+	for level, tc := range []struct {
+		g                  testGeoTIFF
+		expectedPixelScale float64
+	}{
+		{g: g, expectedPixelScale: 10},
+		{g: overview2, expectedPixelScale: 20},
+		{g: overview4, expectedPixelScale: 40},
+	} {
+		levelTile := geoTIFFTile.Level(level)
+		width, length := levelTile.Size()
+		assert.Equal(t, tc.g.imageWidth, width)
+		assert.Equal(t, tc.g.imageLength, length)
+		pixelScaleX, pixelScaleY := levelTile.PixelScale()
+		assert.Equal(t, tc.expectedPixelScale, pixelScaleX)
+		assert.Equal(t, tc.expectedPixelScale, pixelScaleY)
+		assert.True(t, levelTile.integerGeoreferencing)
+
+		tc.g.pixelScale = []float64{tc.expectedPixelScale, tc.expectedPixelScale, 0}
+		tc.g.tiepoint = g.tiepoint
+		assertTestGeoTIFFTileSamples(t, levelTile, tc.g)
+		visitAllTiles(t, levelTile)
+		testSampleSamplesEquivalence(t, levelTile)
+	}
+
+	// All levels share a single tile cache.
+	ifdOffsets := make(map[uint64]bool)
+	for level := range 3 {
+		assert.True(t, geoTIFFTile.tileSamplesCache == geoTIFFTile.Level(level).tileSamplesCache)
+		ifdOffsets[geoTIFFTile.Level(level).ifdOffset] = true
+	}
+	for key := range geoTIFFTile.tileSamplesCache.Keys() {
+		assert.True(t, ifdOffsets[key.ifdOffset])
+	}
+	assert.Equal(t, 3, len(ifdOffsets))
+
+	for _, tc := range []struct {
+		pixelScale    float64
+		expectedLevel int
+	}{
+		{pixelScale: 5, expectedLevel: 0},
+		{pixelScale: 10, expectedLevel: 0},
+		{pixelScale: 25, expectedLevel: 1},
+		{pixelScale: 40, expectedLevel: 2},
+		{pixelScale: 1000, expectedLevel: 2},
+	} {
+		assert.Equal(t, tc.expectedLevel, geoTIFFTile.LevelForPixelScale(tc.pixelScale))
+	}
+
+	// Closing an overview does not close the shared file.
+	assert.NoError(t, geoTIFFTile.Level(1).Close())
+	assert.False(t, geoTIFFTile.file.closed.Load())
+}
+
+func TestGeoTIFFTile_FS(t *testing.T) {
+	g := newTestGeoTIFF()
+	data := g.bytes()
+
+	newZipFS := func(method uint16) fs.FS {
+		var buf bytes.Buffer
+		zipWriter := zip.NewWriter(&buf)
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   "test.tif",
+			Method: method,
+		})
+		assert.NoError(t, err)
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, zipWriter.Close())
+		zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+		return zipReader
+	}
+
+	for _, tc := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{
+			name: "map_fs",
+			fsys: fstest.MapFS{
+				"test.tif": &fstest.MapFile{Data: data},
+			},
+		},
+		{
+			name: "read_seeker",
+			fsys: readSeekerFS{
+				fsys: fstest.MapFS{
+					"test.tif": &fstest.MapFile{Data: data},
+				},
+			},
+		},
+		{
+			name: "zip_store",
+			fsys: newZipFS(zip.Store),
+		},
+		{
+			name: "zip_deflate",
+			fsys: newZipFS(zip.Deflate),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			geoTIFFTile, err := NewGeoTIFFTile(tc.fsys, "test.tif")
+			assert.NoError(t, err)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			assert.NoError(t, geoTIFFTile.Close())
+		})
+	}
+
+	t.Run("reader_at", func(t *testing.T) {
+		geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+		assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+		assert.NoError(t, geoTIFFTile.Close())
+	})
+
+	t.Run("not_exist", func(t *testing.T) {
+		_, err := NewGeoTIFFTile(fstest.MapFS{}, "test.tif")
+		assert.IsError(t, err, fs.ErrNotExist)
+	})
+}
+
+// A readSeekerFS is an fs.FS whose files only implement io.ReadSeeker.
+type readSeekerFS struct {
+	fsys fs.FS
+}
+
+func (fsys readSeekerFS) Open(name string) (fs.File, error) {
+	file, err := fsys.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readSeekerFile{file: file}, nil
+}
+
+// A readSeekerFile is an fs.File that only implements io.ReadSeeker.
+type readSeekerFile struct {
+	file fs.File
+}
+
+func (f readSeekerFile) Close() error               { return f.file.Close() }
+func (f readSeekerFile) Read(p []byte) (int, error) { return f.file.Read(p) }
+func (f readSeekerFile) Stat() (fs.FileInfo, error) { return f.file.Stat() }
+
+func (f readSeekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.(io.Seeker).Seek(offset, whence)
+}
+
+func TestGeoTIFFTile_Mmap(t *testing.T) {
+	g := newTestGeoTIFF()
+	geoTIFFTile := newTestGeoTIFFTile(t, g, WithMmap(true))
+	if runtime.GOOS == "linux" {
+		assert.NotZero(t, geoTIFFTile.mmapData)
+	} else {
+		assert.Zero(t, geoTIFFTile.mmapData)
+	}
+	assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+	visitAllTiles(t, geoTIFFTile)
+	testSampleSamplesEquivalence(t, geoTIFFTile)
+
+	// Files that are not *os.Files are read normally.
+	data := g.bytes()
+	geoTIFFTile, err := NewGeoTIFFTile(fstest.MapFS{"test.tif": &fstest.MapFile{Data: data}}, "test.tif", WithMmap(true))
+	assert.NoError(t, err)
+	assert.Zero(t, geoTIFFTile.mmapData)
+	assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+	assert.NoError(t, geoTIFFTile.Close())
+}
+
+func TestGeoTIFFTile_Close(t *testing.T) {
+	g := newTestGeoTIFF()
+	data := g.bytes()
+	coord := Coord{X: 1005, Y: 1995}
+
+	for _, tc := range []struct {
+		name           string
+		newGeoTIFFTile func() (*GeoTIFFTile, error)
+	}{
+		{
+			name: "fs",
+			newGeoTIFFTile: func() (*GeoTIFFTile, error) {
+				return NewGeoTIFFTile(fstest.MapFS{"test.tif": &fstest.MapFile{Data: data}}, "test.tif")
+			},
+		},
+		{
+			name: "reader_at",
+			newGeoTIFFTile: func() (*GeoTIFFTile, error) {
+				return NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			geoTIFFTile, err := tc.newGeoTIFFTile()
+			assert.NoError(t, err)
+
+			// Hold a reference, as GeoTIFFTileSet does while sampling, and
+			// close the tile.
+			assert.True(t, geoTIFFTile.file.acquire())
+			assert.NoError(t, geoTIFFTile.Close())
+			assert.NoError(t, geoTIFFTile.Close())
+
+			// New samples fail, even though the file is still open.
+			_, err = geoTIFFTile.Sample(t.Context(), coord)
+			assert.IsError(t, err, fs.ErrClosed)
+			_, err = geoTIFFTile.Samples(t.Context(), []Coord{coord})
+			assert.IsError(t, err, fs.ErrClosed)
+			_, err = geoTIFFTile.SampleFloat64(t.Context(), Float64Coord{X: 1005, Y: 1995})
+			assert.IsError(t, err, fs.ErrClosed)
+			_, err = geoTIFFTile.SamplesFloat64(t.Context(), []Float64Coord{{X: 1005, Y: 1995}})
+			assert.IsError(t, err, fs.ErrClosed)
+			_, err = geoTIFFTile.SampleBands(t.Context(), coord)
+			assert.IsError(t, err, fs.ErrClosed)
+			_, err = geoTIFFTile.SampleBandsFloat64(t.Context(), Float64Coord{X: 1005, Y: 1995})
+			assert.IsError(t, err, fs.ErrClosed)
+			assert.False(t, geoTIFFTile.file.acquire())
+
+			// The holder of the reference can still sample, until it
+			// releases the reference and the file is closed.
+			samples, err := geoTIFFTile.samples(t.Context(), []Coord{coord})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{g.sample(0, 0)}, samples)
+			assert.Equal(t, 1, geoTIFFTile.file.refs.Load())
+			assert.NoError(t, geoTIFFTile.file.release())
+			assert.Equal(t, 0, geoTIFFTile.file.refs.Load())
+		})
+	}
+}
+
+func BenchmarkGeoTIFFTile_Samples(b *testing.B) {
+	g := newTestGeoTIFF()
+	g.imageWidth = 4096
+	g.imageLength = 4096
+	g.tileWidth = 256
+	g.tileLength = 256
+	g.isNoData = func(x, y int) bool {
+		return false
+	}
+	dir := b.TempDir()
+	assert.NoError(b, os.WriteFile(filepath.Join(dir, "test.tif"), g.bytes(), 0o666))
+
+	for _, bc := range []struct {
+		name string
+		mmap bool
+	}{
+		{name: "read_at"},
+		{name: "mmap", mmap: true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			// Use a cache that holds a single tile so that almost every
+			// tile is read again in each batch.
+			geoTIFFTile, err := NewGeoTIFFTile(os.DirFS(dir), "test.tif",
+				WithMmap(bc.mmap),
+				WithTileCacheSize(g.tileWidth*g.tileLength*4),
+			)
+			assert.NoError(b, err)
+			defer func() {
+				assert.NoError(b, geoTIFFTile.Close())
+			}()
+
+			r := rand.New(rand.NewPCG(0, 0))
+			coords := make([]Float64Coord, 1024)
+			for i := range coords {
+				coords[i] = Float64Coord{
+					X: g.tiepoint[3] + g.pixelScale[0]*r.Float64()*float64(g.imageWidth),
+					Y: g.tiepoint[4] - g.pixelScale[1]*r.Float64()*float64(g.imageLength),
+				}
+			}
+
+			for b.Loop() {
+				if _, err := geoTIFFTile.SamplesFloat64(b.Context(), coords); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_DirectSamples(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		rowsPerStrip          int
+		predictor             uint16
+		options               []GeoTIFFTileOption
+		expectedDirectSamples bool
+	}{
+		{
+			name: "tiles",
+		},
+		{
+			name:                  "tiles_direct",
+			options:               []GeoTIFFTileOption{WithDirectSampleReads(true)},
+			expectedDirectSamples: true,
+		},
+		{
+			name:                  "tiles_mmap",
+			options:               []GeoTIFFTileOption{WithMmap(true)},
+			expectedDirectSamples: runtime.GOOS == "linux",
+		},
+		{
+			name:                  "strips_direct",
+			rowsPerStrip:          7,
+			options:               []GeoTIFFTileOption{WithDirectSampleReads(true)},
+			expectedDirectSamples: true,
+		},
+		{
+			name:         "strips_grouped_direct",
+			rowsPerStrip: 3,
+			options: []GeoTIFFTileOption{
+				WithDirectSampleReads(true),
+				func(f *GeoTIFFTile) {
+					f.stripGroupSizeBytes = 4 * 3 * 300 * 4
+				},
+			},
+			expectedDirectSamples: true,
+		},
+		{
+			name:      "predictor",
+			predictor: predictorHorizontal,
+			options:   []GeoTIFFTileOption{WithDirectSampleReads(true)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.compression = CompressionNone
+			g.compress = slices.Clone[[]byte]
+			if tc.predictor != 0 {
+				g.predictor = tc.predictor
+			}
+			if tc.rowsPerStrip != 0 {
+				g.tileWidth = 0
+				g.tileLength = 0
+				g.rowsPerStrip = tc.rowsPerStrip
+			}
+			geoTIFFTile := newTestGeoTIFFTile(t, g, tc.options...)
+			assert.Equal(t, tc.expectedDirectSamples, geoTIFFTile.directSamples)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+			testSampleSamplesEquivalence(t, geoTIFFTile)
+			if tc.expectedDirectSamples {
+				assert.Equal(t, 0, geoTIFFTile.tileSamplesCache.EstimatedSize())
+			} else {
+				assert.NotEqual(t, 0, geoTIFFTile.tileSamplesCache.EstimatedSize())
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_Bands(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		planar       bool
+		rowsPerStrip int
+		compression  uint16
+		predictor    uint16
+		options      []GeoTIFFTileOption
+	}{
+		{
+			name: "chunky",
+		},
+		{
+			name:      "chunky_horizontal",
+			predictor: predictorHorizontal,
+		},
+		{
+			name:      "chunky_floating_point",
+			predictor: predictorFloatingPoint,
+		},
+		{
+			name:         "chunky_strips",
+			rowsPerStrip: 7,
+		},
+		{
+			name:        "chunky_direct",
+			compression: CompressionNone,
+			options:     []GeoTIFFTileOption{WithDirectSampleReads(true)},
+		},
+		{
+			name:   "planar",
+			planar: true,
+		},
+		{
+			name:      "planar_floating_point",
+			planar:    true,
+			predictor: predictorFloatingPoint,
+		},
+		{
+			name:         "planar_strips",
+			planar:       true,
+			rowsPerStrip: 7,
+		},
+		{
+			name:         "planar_strips_direct",
+			planar:       true,
+			rowsPerStrip: 7,
+			compression:  CompressionNone,
+			options:      []GeoTIFFTileOption{WithDirectSampleReads(true)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.bands = 3
+			g.planar = tc.planar
+			if tc.rowsPerStrip != 0 {
+				g.tileWidth = 0
+				g.tileLength = 0
+				g.rowsPerStrip = tc.rowsPerStrip
+			}
+			if tc.compression != 0 {
+				g.compression = tc.compression
+				g.compress = slices.Clone[[]byte]
+			}
+			g.predictor = tc.predictor
+
+			for band := range g.bands {
+				geoTIFFTile := newTestGeoTIFFTile(t, g, append(slices.Clone(tc.options), WithBand(band))...)
+				assert.Equal(t, g.bands, geoTIFFTile.Bands())
+				assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+
+				// Planar images only read the plane of the selected band.
+				for key := range geoTIFFTile.tileSamplesCache.Keys() {
+					if tc.planar {
+						assert.Equal(t, band, key.plane)
+					} else {
+						assert.Equal(t, 0, key.plane)
+					}
+				}
+			}
+
+			geoTIFFTile := newTestGeoTIFFTile(t, g, tc.options...)
+			for _, pixel := range []Coord{{X: 0, Y: 0}, {X: 299, Y: 0}, {X: 150, Y: 100}, {X: 299, Y: 199}} {
+				coord := Coord{X: 1000 + 10*pixel.X + 5, Y: 2000 - 10*pixel.Y - 5}
+				samples, err := geoTIFFTile.SampleBands(t.Context(), coord)
+				assert.NoError(t, err)
+				expected := make([]float64, g.bands)
+				for band := range expected {
+					if g.isNoData(pixel.X, pixel.Y) {
+						expected[band] = math.NaN()
+					} else {
+						expected[band] = g.bandSample(pixel.X, pixel.Y, band)
+					}
+				}
+				assert.Equal(t, expected, samples)
+
+				float64Coord := Float64Coord{X: float64(1000+10*pixel.X) + 2.5, Y: float64(2000-10*pixel.Y) - 7.5}
+				samples, err = geoTIFFTile.SampleBandsFloat64(t.Context(), float64Coord)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, samples)
+			}
+
+			// Coordinates outside the image have NaN samples in every band.
+			samples, err := geoTIFFTile.SampleBands(t.Context(), Coord{X: 0, Y: 0})
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(samples))
+			for _, sample := range samples {
+				assert.True(t, math.IsNaN(sample))
+			}
+			samples, err = geoTIFFTile.SampleBandsFloat64(t.Context(), Float64Coord{X: 999.5, Y: 1995})
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(samples))
+			for _, sample := range samples {
+				assert.True(t, math.IsNaN(sample))
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTile_BandOutOfRange(t *testing.T) {
+	g := newTestGeoTIFF()
+	g.bands = 2
+	for _, band := range []int{-1, 2} {
+		_, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(g.bytes()), int64(len(g.bytes())), WithBand(band))
+		assert.Error(t, err)
+	}
+}
+
+func TestGeoTIFFTile_GDALMetadata(t *testing.T) {
+	// Heights are stored in decimetres relative to -100m in the first band.
+	// The second band is unscaled.
+	g := newTestGeoTIFF()
+	g.sampleFormat = sampleFormatInt
+	g.bitsPerSample = 16
+	g.bands = 2
+	g.sample = func(x, y int) float64 {
+		return float64(x - y)
+	}
+	g.noDataSample = -32768
+	g.noData = "-32768"
+	g.gdalMetadata = `<GDALMetadata>
+  <Item name="AREA_OR_POINT">Area</Item>
+  <Item name="SCALE" sample="0" role="scale">0.1</Item>
+  <Item name="OFFSET" sample="0" role="offset">-100</Item>
+  <Item name="DESCRIPTION" sample="0" role="description">Elevation</Item>
+  <Item name="UNITTYPE" sample="0" role="unittype">m</Item>
+</GDALMetadata>`
+
+	coords := make([]Coord, 0, len(testGeoTIFFPixelCenters(g)))
+	for _, float64Coord := range testGeoTIFFPixelCenters(g) {
+		coords = append(coords, Coord{X: int(float64Coord.X), Y: int(float64Coord.Y)})
+	}
+
+	for band, expectedScaleOffset := range [][2]float64{{0.1, -100}, {1, 0}} {
+		geoTIFFTile := newTestGeoTIFFTile(t, g, WithBand(band))
+		gdalMetadata := geoTIFFTile.GDALMetadata()
+		assert.NotZero(t, gdalMetadata)
+		assert.Equal(t, "Area", gdalMetadata.Dataset["AREA_OR_POINT"])
+		assert.Equal(t, "Elevation", gdalMetadata.Bands[0].Description)
+		assert.Equal(t, "m", gdalMetadata.Bands[0].UnitType)
+
+		unscaledGeoTIFFTile := newTestGeoTIFFTile(t, g, WithBand(band), WithGDALScaleOffset(false))
+		assertTestGeoTIFFTileSamples(t, unscaledGeoTIFFTile, g)
+
+		samples, err := geoTIFFTile.Samples(t.Context(), coords)
+		assert.NoError(t, err)
+		unscaledSamples, err := unscaledGeoTIFFTile.Samples(t.Context(), coords)
+		assert.NoError(t, err)
+		for i, unscaledSample := range unscaledSamples {
+			if math.IsNaN(unscaledSample) {
+				assert.True(t, math.IsNaN(samples[i]))
+			} else {
+				assert.Equal(t, expectedScaleOffset[0]*unscaledSample+expectedScaleOffset[1], samples[i])
+			}
+		}
+
+		sample, err := geoTIFFTile.Sample(t.Context(), coords[1])
+		assert.NoError(t, err)
+		assert.Equal(t, samples[1], sample)
+	}
+
+	g.gdalMetadata = ""
+	geoTIFFTile := newTestGeoTIFFTile(t, g)
+	assert.Zero(t, geoTIFFTile.GDALMetadata())
+	assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+
+	// Unknown items and items for other bands are ignored.
+	g.gdalMetadata = `<GDALMetadata>
+  <Item name="UNKNOWN" sample="0">x</Item>
+  <Item name="SCALE" sample="2" role="scale">x</Item>
+</GDALMetadata>`
+	geoTIFFTile = newTestGeoTIFFTile(t, g)
+	assert.Equal(t, "x", geoTIFFTile.GDALMetadata().Bands[0].Items["UNKNOWN"])
+	assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+
+	// Malformed metadata and unparsable scales and offsets are errors.
+	for _, gdalMetadata := range []string{
+		"<GDALMetadata>",
+		`<GDALMetadata><Item name="SCALE" sample="0" role="scale">x</Item></GDALMetadata>`,
+		`<GDALMetadata><Item name="OFFSET" sample="1" role="offset">x</Item></GDALMetadata>`,
+	} {
+		g.gdalMetadata = gdalMetadata
+		_, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(g.bytes()), int64(len(g.bytes())))
+		assert.Error(t, err)
+	}
+}
+
+func TestGeoTIFFTile_LargeDimensions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		imageWidth   int
+		imageLength  int
+		tileWidth    int
+		tileLength   int
+		rowsPerStrip int
+		bigTIFF      bool
+	}{
+		{
+			name:        "wide",
+			imageWidth:  70000,
+			imageLength: 4,
+			tileWidth:   1024,
+			tileLength:  16,
+		},
+		{
+			name:         "tall",
+			imageWidth:   2,
+			imageLength:  70000,
+			rowsPerStrip: 1024,
+		},
+		{
+			name:        "bigtiff_wide",
+			imageWidth:  70000,
+			imageLength: 4,
+			tileWidth:   1024,
+			tileLength:  16,
+			bigTIFF:     true,
+		},
+		{
+			name:         "bigtiff_tall",
+			imageWidth:   2,
+			imageLength:  70000,
+			rowsPerStrip: 1024,
+			bigTIFF:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGeoTIFF()
+			g.imageWidth = tc.imageWidth
+			g.imageLength = tc.imageLength
+			g.tileWidth = tc.tileWidth
+			g.tileLength = tc.tileLength
+			g.rowsPerStrip = tc.rowsPerStrip
+			g.sample = func(x, y int) float64 {
+				return float64(x + 7*y)
+			}
+			g.isNoData = func(x, y int) bool {
+				return x == y
+			}
+
+			var geoTIFFTile *GeoTIFFTile
+			if tc.bigTIFF {
+				// Store dimensions as LONG8s and chunks beyond 4GB.
+				ifd := g.ifd()
+				for i, field := range ifd.fields {
+					switch field.tag {
+					case 256, 257, 278, 322, 323:
+						switch value := field.value.(type) {
+						case []uint16:
+							ifd.fields[i].value = []uint64{uint64(value[0])}
+						case []uint32:
+							ifd.fields[i].value = []uint64{uint64(value[0])}
+						}
+					}
+				}
+				file := encodeTestBigTIFF(g.byteOrder, []testTIFFIFD{ifd}, 5<<30)
+				var err error
+				geoTIFFTile, err = NewGeoTIFFTileFromReaderAt(file, file.size)
+				assert.NoError(t, err)
+				assert.True(t, geoTIFFTile.chunkOffsets[0] > math.MaxUint32)
+			} else {
+				geoTIFFTile = newTestGeoTIFFTile(t, g)
+			}
+
+			width, length := geoTIFFTile.Size()
+			assert.Equal(t, tc.imageWidth, width)
+			assert.Equal(t, tc.imageLength, length)
+			assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+		})
+	}
+}
+
+func TestGeoTIFFTile_UnsupportedCompression(t *testing.T) {
+	g := newTestGeoTIFF()
+	g.compression = 34712 // JPEG 2000.
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "test.tif"), g.bytes(), 0o666))
+	_, err := NewGeoTIFFTile(os.DirFS(dir), "test.tif")
+	assert.IsError(t, err, errors.ErrUnsupported)
+
+	geoTIFFTile := newTestGeoTIFFTile(t, g, WithDecompressFunc(34712, decompressDeflate))
+	assertTestGeoTIFFTileSamples(t, geoTIFFTile, g)
+
+	// LERC always stores samples in little-endian byte order.
+	g = newTestGeoTIFF()
+	g.byteOrder = binary.BigEndian
+	g.compression = CompressionLERC
+	g.compress = compressLERCFloat32
+	_, err = NewGeoTIFFTileFromReaderAt(bytes.NewReader(g.bytes()), int64(len(g.bytes())))
+	assert.IsError(t, err, errors.ErrUnsupported)
+}
+
 func visitAllTiles(t *testing.T, f *GeoTIFFTile) {
 	t.Helper()
 	for r := range f.tilesDown {
 		for c := range f.tilesAcross {
-			if _, err := f.getTileSamplesCached(t.Context(), TileCoord{C: c, R: r}); err != nil {
-				assert.IsError(t, err, otter.ErrNotFound)
-			}
+			_, err := f.getTileSamplesCached(t.Context(), f.tileKey(TileCoord{C: c, R: r}, f.band))
+			assert.NoError(t, err)
 		}
 	}
 }
@@ -146,3 +1763,519 @@ func testSampleSamplesEquivalence(t *testing.T, f *GeoTIFFTile) {
 		assert.Equal(t, sampleCoords, samplesCoords)
 	}
 }
+
+// A testByteOrder is a byte order that can both read and append values.
+type testByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// A testTIFFField is a field in a test TIFF IFD. The TIFF field type is
+// determined by the Go type of value.
+type testTIFFField struct {
+	tag   uint16
+	value any
+}
+
+// A testTIFFIFD is an IFD in a test TIFF file. The chunks are written to the
+// file and their offsets and byte counts are stored in the offsetsTag and
+// byteCountsTag fields.
+type testTIFFIFD struct {
+	fields        []testTIFFField
+	offsetsTag    uint16
+	byteCountsTag uint16
+	chunks        [][]byte
+}
+
+// encodeTestTIFF returns a TIFF file containing ifds.
+func encodeTestTIFF(byteOrder testByteOrder, ifds []testTIFFIFD) []byte {
+	var buf []byte
+	if byteOrder == binary.BigEndian {
+		buf = append(buf, 'M', 'M')
+	} else {
+		buf = append(buf, 'I', 'I')
+	}
+	buf = byteOrder.AppendUint16(buf, 42)
+	nextIFDOffsetIndex := len(buf)
+	buf = byteOrder.AppendUint32(buf, 0)
+
+	for _, ifd := range ifds {
+		fields := slices.Clone(ifd.fields)
+		if ifd.offsetsTag != 0 {
+			offsets := make([]uint32, len(ifd.chunks))
+			byteCounts := make([]uint32, len(ifd.chunks))
+			for i, chunk := range ifd.chunks {
+				if len(chunk) != 0 {
+					offsets[i] = uint32(len(buf))
+				}
+				byteCounts[i] = uint32(len(chunk))
+				buf = append(buf, chunk...)
+			}
+			fields = append(fields,
+				testTIFFField{tag: ifd.offsetsTag, value: offsets},
+				testTIFFField{tag: ifd.byteCountsTag, value: byteCounts},
+			)
+		}
+		slices.SortFunc(fields, func(a, b testTIFFField) int {
+			return int(a.tag) - int(b.tag)
+		})
+
+		if len(buf)%2 != 0 {
+			buf = append(buf, 0)
+		}
+		byteOrder.PutUint32(buf[nextIFDOffsetIndex:], uint32(len(buf)))
+		ifdOffset := len(buf)
+		externalDataOffset := ifdOffset + 2 + 12*len(fields) + 4
+		var externalData []byte
+		buf = byteOrder.AppendUint16(buf, uint16(len(fields)))
+		for _, field := range fields {
+			fieldType, count, data := encodeTestTIFFFieldValue(byteOrder, field.value)
+			buf = byteOrder.AppendUint16(buf, field.tag)
+			buf = byteOrder.AppendUint16(buf, fieldType)
+			buf = byteOrder.AppendUint32(buf, uint32(count))
+			if len(data) <= 4 {
+				buf = append(buf, data...)
+				buf = append(buf, make([]byte, 4-len(data))...)
+			} else {
+				buf = byteOrder.AppendUint32(buf, uint32(externalDataOffset+len(externalData)))
+				externalData = append(externalData, data...)
+				if len(externalData)%2 != 0 {
+					externalData = append(externalData, 0)
+				}
+			}
+		}
+		nextIFDOffsetIndex = len(buf)
+		buf = byteOrder.AppendUint32(buf, 0)
+		buf = append(buf, externalData...)
+	}
+
+	return buf
+}
+
+// encodeTestBigTIFF returns a BigTIFF file containing ifds. The chunks are
+// stored consecutively starting at chunksOffset, which may be beyond 4GB. The
+// gap between the IFDs and the chunks is not stored.
+func encodeTestBigTIFF(byteOrder testByteOrder, ifds []testTIFFIFD, chunksOffset uint64) *testSparseFile {
+	var buf []byte
+	if byteOrder == binary.BigEndian {
+		buf = append(buf, 'M', 'M')
+	} else {
+		buf = append(buf, 'I', 'I')
+	}
+	buf = byteOrder.AppendUint16(buf, 43)
+	buf = byteOrder.AppendUint16(buf, 8) // Bytesize of offsets.
+	buf = byteOrder.AppendUint16(buf, 0)
+	nextIFDOffsetIndex := len(buf)
+	buf = byteOrder.AppendUint64(buf, 0)
+
+	var chunks []byte
+	for _, ifd := range ifds {
+		fields := slices.Clone(ifd.fields)
+		if ifd.offsetsTag != 0 {
+			offsets := make([]uint64, len(ifd.chunks))
+			byteCounts := make([]uint64, len(ifd.chunks))
+			for i, chunk := range ifd.chunks {
+				offsets[i] = chunksOffset + uint64(len(chunks))
+				byteCounts[i] = uint64(len(chunk))
+				chunks = append(chunks, chunk...)
+			}
+			fields = append(fields,
+				testTIFFField{tag: ifd.offsetsTag, value: offsets},
+				testTIFFField{tag: ifd.byteCountsTag, value: byteCounts},
+			)
+		}
+		slices.SortFunc(fields, func(a, b testTIFFField) int {
+			return int(a.tag) - int(b.tag)
+		})
+
+		if len(buf)%2 != 0 {
+			buf = append(buf, 0)
+		}
+		byteOrder.PutUint64(buf[nextIFDOffsetIndex:], uint64(len(buf)))
+		ifdOffset := len(buf)
+		externalDataOffset := ifdOffset + 8 + 20*len(fields) + 8
+		var externalData []byte
+		buf = byteOrder.AppendUint64(buf, uint64(len(fields)))
+		for _, field := range fields {
+			fieldType, count, data := encodeTestTIFFFieldValue(byteOrder, field.value)
+			buf = byteOrder.AppendUint16(buf, field.tag)
+			buf = byteOrder.AppendUint16(buf, fieldType)
+			buf = byteOrder.AppendUint64(buf, uint64(count))
+			if len(data) <= 8 {
+				buf = append(buf, data...)
+				buf = append(buf, make([]byte, 8-len(data))...)
+			} else {
+				buf = byteOrder.AppendUint64(buf, uint64(externalDataOffset+len(externalData)))
+				externalData = append(externalData, data...)
+				if len(externalData)%2 != 0 {
+					externalData = append(externalData, 0)
+				}
+			}
+		}
+		nextIFDOffsetIndex = len(buf)
+		buf = byteOrder.AppendUint64(buf, 0)
+		buf = append(buf, externalData...)
+	}
+	if uint64(len(buf)) > chunksOffset {
+		panic("chunks overlap IFDs")
+	}
+
+	return &testSparseFile{
+		segments: map[int64][]byte{
+			0:                   buf,
+			int64(chunksOffset): chunks,
+		},
+		size: int64(chunksOffset) + int64(len(chunks)),
+	}
+}
+
+// A testSparseFile is a file of size bytes that only stores non-zero
+// segments, keyed by offset.
+type testSparseFile struct {
+	segments map[int64][]byte
+	size     int64
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *testSparseFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), f.size-off))
+	clear(p[:n])
+	for segmentOffset, segment := range f.segments {
+		start := max(segmentOffset, off)
+		end := min(segmentOffset+int64(len(segment)), off+int64(n))
+		if start < end {
+			copy(p[start-off:end-off], segment[start-segmentOffset:end-segmentOffset])
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// encodeTestTIFFFieldValue returns the TIFF field type, count, and encoded data
+// of value.
+func encodeTestTIFFFieldValue(byteOrder testByteOrder, value any) (uint16, int, []byte) {
+	switch value := value.(type) {
+	case string:
+		return 2, len(value) + 1, append([]byte(value), 0)
+	case []uint16:
+		var data []byte
+		for _, v := range value {
+			data = byteOrder.AppendUint16(data, v)
+		}
+		return 3, len(value), data
+	case []uint32:
+		var data []byte
+		for _, v := range value {
+			data = byteOrder.AppendUint32(data, v)
+		}
+		return 4, len(value), data
+	case []uint64:
+		var data []byte
+		for _, v := range value {
+			data = byteOrder.AppendUint64(data, v)
+		}
+		return 16, len(value), data
+	case []float64:
+		var data []byte
+		for _, v := range value {
+			data = byteOrder.AppendUint64(data, math.Float64bits(v))
+		}
+		return 12, len(value), data
+	default:
+		panic(fmt.Sprintf("%T: unsupported type", value))
+	}
+}
+
+// A testGeoTIFF describes a single-band GeoTIFF file for testing.
+type testGeoTIFF struct {
+	byteOrder       testByteOrder
+	imageWidth      int
+	imageLength     int
+	tileWidth       int
+	tileLength      int
+	rowsPerStrip    int
+	compression     uint16
+	compress        func([]byte) []byte
+	predictor       uint16
+	sampleFormat    uint16
+	bitsPerSample   uint16
+	bands           int
+	planar          bool
+	sample          func(x, y int) float64
+	isNoData        func(x, y int) bool
+	noDataSample    float64
+	noData          string
+	gdalMetadata    string
+	pixelScale      []float64
+	tiepoint        []float64
+	transform       []float64
+	geoKeys         []uint16
+	geoDoubleParams []float64
+	newSubfileType  uint32
+	sparse          bool
+}
+
+// bytes returns the encoded GeoTIFF.
+func (g testGeoTIFF) bytes() []byte {
+	return encodeTestTIFF(g.byteOrder, []testTIFFIFD{g.ifd()})
+}
+
+// ifd returns g's IFD.
+func (g testGeoTIFF) ifd() testTIFFIFD {
+	planarConfiguration := uint16(planarConfigurationChunky)
+	if g.planar {
+		planarConfiguration = planarConfigurationPlanar
+	}
+	fields := []testTIFFField{
+		{tag: 256, value: testTIFFDimension(g.imageWidth)},
+		{tag: 257, value: testTIFFDimension(g.imageLength)},
+		{tag: 258, value: slices.Repeat([]uint16{g.bitsPerSample}, g.bandCount())},
+		{tag: 259, value: []uint16{g.compression}},
+		{tag: 262, value: []uint16{1}},
+		{tag: 277, value: []uint16{uint16(g.bandCount())}},
+		{tag: 284, value: []uint16{planarConfiguration}},
+		{tag: 317, value: []uint16{g.predictor}},
+		{tag: 339, value: slices.Repeat([]uint16{g.sampleFormat}, g.bandCount())},
+	}
+	if g.geoKeys != nil {
+		fields = append(fields, testTIFFField{tag: 34735, value: g.geoKeys})
+	}
+	if g.geoDoubleParams != nil {
+		fields = append(fields, testTIFFField{tag: 34736, value: g.geoDoubleParams})
+	}
+	if g.newSubfileType != 0 {
+		fields = append(fields, testTIFFField{tag: 254, value: []uint32{g.newSubfileType}})
+	}
+	switch {
+	case g.transform != nil:
+		fields = append(fields, testTIFFField{tag: 34264, value: g.transform})
+	case g.pixelScale != nil:
+		fields = append(fields,
+			testTIFFField{tag: 33550, value: g.pixelScale},
+			testTIFFField{tag: 33922, value: g.tiepoint},
+		)
+	}
+	if g.gdalMetadata != "" {
+		fields = append(fields, testTIFFField{tag: 42112, value: g.gdalMetadata})
+	}
+	if g.noData != "" {
+		fields = append(fields, testTIFFField{tag: 42113, value: g.noData})
+	}
+
+	ifd := testTIFFIFD{
+		fields: fields,
+	}
+	planes := []int{-1}
+	if g.planar {
+		planes = make([]int, g.bandCount())
+		for band := range planes {
+			planes[band] = band
+		}
+	}
+	if g.rowsPerStrip != 0 {
+		for _, plane := range planes {
+			for y := 0; y < g.imageLength; y += g.rowsPerStrip {
+				ifd.chunks = append(ifd.chunks, g.chunk(0, y, g.imageWidth, min(g.rowsPerStrip, g.imageLength-y), plane))
+			}
+		}
+		ifd.fields = append(ifd.fields, testTIFFField{tag: 278, value: []uint32{uint32(g.rowsPerStrip)}})
+		ifd.offsetsTag = 273
+		ifd.byteCountsTag = 279
+	} else {
+		for _, plane := range planes {
+			for y := 0; y < g.imageLength; y += g.tileLength {
+				for x := 0; x < g.imageWidth; x += g.tileWidth {
+					ifd.chunks = append(ifd.chunks, g.chunk(x, y, g.tileWidth, g.tileLength, plane))
+				}
+			}
+		}
+		ifd.fields = append(ifd.fields,
+			testTIFFField{tag: 322, value: testTIFFDimension(g.tileWidth)},
+			testTIFFField{tag: 323, value: testTIFFDimension(g.tileLength)},
+		)
+		ifd.offsetsTag = 324
+		ifd.byteCountsTag = 325
+	}
+
+	return ifd
+}
+
+// testTIFFDimension returns the value of a TIFF dimension field, which is a
+// SHORT if possible and a LONG otherwise.
+func testTIFFDimension(n int) any {
+	if n <= math.MaxUint16 {
+		return []uint16{uint16(n)}
+	}
+	return []uint32{uint32(n)}
+}
+
+// chunk returns the encoded, compressed chunk of width by length pixels at
+// x0, y0. If plane is negative then the chunk contains the samples of all
+// bands, otherwise it contains only the samples of band plane. If g is sparse
+// then chunks whose samples are all g's nodata sample are empty.
+func (g testGeoTIFF) chunk(x0, y0, width, length, plane int) []byte {
+	bands := []int{plane}
+	if plane < 0 {
+		bands = make([]int, g.bandCount())
+		for band := range bands {
+			bands[band] = band
+		}
+	}
+	var data []byte
+	empty := true
+	for y := y0; y < y0+length; y++ {
+		for x := x0; x < x0+width; x++ {
+			for _, band := range bands {
+				sample := g.noDataSample
+				if x < g.imageWidth && y < g.imageLength && !g.isNoData(x, y) {
+					sample = g.bandSample(x, y, band)
+				}
+				if sample != g.noDataSample {
+					empty = false
+				}
+				data = appendTestSample(data, g.byteOrder, g.sampleFormat, g.bitsPerSample, sample)
+			}
+		}
+	}
+	if g.sparse && empty {
+		return nil
+	}
+	bytesPerSample := int(g.bitsPerSample) / 8
+	switch g.predictor {
+	case predictorHorizontal:
+		data = applyHorizontalDifferencing(data, width, len(bands), bytesPerSample, g.byteOrder)
+	case predictorFloatingPoint:
+		data = applyFloatingPointPredictor(data, width, len(bands), bytesPerSample, g.byteOrder)
+	}
+	return g.compress(data)
+}
+
+// bandCount returns the number of bands in g.
+func (g testGeoTIFF) bandCount() int {
+	return max(g.bands, 1)
+}
+
+// bandSample returns the sample of band at x, y. Each band's samples are
+// offset from the previous band's samples by one.
+func (g testGeoTIFF) bandSample(x, y, band int) float64 {
+	return g.sample(x, y) + float64(band)
+}
+
+// appendTestSample appends sample to data encoded with the given sample
+// format and bits per sample in byteOrder.
+func appendTestSample(data []byte, byteOrder testByteOrder, sampleFormat, bitsPerSample uint16, sample float64) []byte {
+	switch {
+	case sampleFormat == sampleFormatIEEEFP && bitsPerSample == 32:
+		return byteOrder.AppendUint32(data, math.Float32bits(float32(sample)))
+	case sampleFormat == sampleFormatIEEEFP && bitsPerSample == 64:
+		return byteOrder.AppendUint64(data, math.Float64bits(sample))
+	case sampleFormat == sampleFormatInt && bitsPerSample == 8:
+		return append(data, byte(int8(sample)))
+	case sampleFormat == sampleFormatInt && bitsPerSample == 16:
+		return byteOrder.AppendUint16(data, uint16(int16(sample)))
+	case sampleFormat == sampleFormatInt && bitsPerSample == 32:
+		return byteOrder.AppendUint32(data, uint32(int32(sample)))
+	case bitsPerSample == 8:
+		return append(data, byte(sample))
+	case bitsPerSample == 16:
+		return byteOrder.AppendUint16(data, uint16(sample))
+	case bitsPerSample == 32:
+		return byteOrder.AppendUint32(data, uint32(sample))
+	default:
+		panic(fmt.Sprintf("sample format %d with %d bits per sample: unsupported", sampleFormat, bitsPerSample))
+	}
+}
+
+// newTestGeoTIFF returns a DEFLATE-compressed float32 test GeoTIFF with
+// partial tiles at its right and bottom edges and an empty tile at its bottom
+// right.
+func newTestGeoTIFF() testGeoTIFF {
+	return testGeoTIFF{
+		byteOrder:     binary.LittleEndian,
+		imageWidth:    300,
+		imageLength:   200,
+		tileWidth:     128,
+		tileLength:    128,
+		compression:   CompressionDeflate,
+		compress:      mustCompress(compressDeflate),
+		predictor:     predictorNone,
+		sampleFormat:  sampleFormatIEEEFP,
+		bitsPerSample: 32,
+		sample: func(x, y int) float64 {
+			return float64(x + 1000*y)
+		},
+		isNoData: func(x, y int) bool {
+			return x >= 256 && y >= 128
+		},
+		noDataSample: -math.MaxFloat32,
+		noData:       "-3.4028234663852886e+038",
+		pixelScale:   []float64{10, 10, 0},
+		tiepoint:     []float64{0, 0, 0, 1000, 2000, 0},
+	}
+}
+
+// assertTestGeoTIFFTileSamples asserts that the sample at the center of every
+// pixel in f matches the corresponding sample in g.
+func assertTestGeoTIFFTileSamples(t *testing.T, f *GeoTIFFTile, g testGeoTIFF) {
+	t.Helper()
+	expected := make([]float64, 0, g.imageWidth*g.imageLength)
+	for y := range g.imageLength {
+		for x := range g.imageWidth {
+			if g.isNoData(x, y) {
+				expected = append(expected, math.NaN())
+			} else {
+				expected = append(expected, g.bandSample(x, y, f.band))
+			}
+		}
+	}
+	float64Coords := testGeoTIFFPixelCenters(g)
+	if f.integerGeoreferencing {
+		coords := make([]Coord, 0, len(float64Coords))
+		for _, float64Coord := range float64Coords {
+			coords = append(coords, Coord{X: int(float64Coord.X), Y: int(float64Coord.Y)})
+		}
+		actual, err := f.Samples(t.Context(), coords)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	actual, err := f.SamplesFloat64(t.Context(), float64Coords)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+// testGeoTIFFPixelCenters returns the coordinates of the center of every pixel
+// in g, in row-major order.
+func testGeoTIFFPixelCenters(g testGeoTIFF) []Float64Coord {
+	var transform affine
+	if g.transform != nil {
+		transform = newAffineFromModelTransformation(g.transform)
+	} else {
+		transform = newAffineFromTiepoint(g.pixelScale[0], g.pixelScale[1], g.tiepoint[0], g.tiepoint[1], g.tiepoint[3], g.tiepoint[4])
+	}
+	coords := make([]Float64Coord, 0, g.imageWidth*g.imageLength)
+	for y := range g.imageLength {
+		for x := range g.imageWidth {
+			modelX, modelY := transform.apply(float64(x)+0.5, float64(y)+0.5)
+			coords = append(coords, Float64Coord{X: modelX, Y: modelY})
+		}
+	}
+	return coords
+}
+
+// newTestGeoTIFFTile writes g to a temporary directory and opens it.
+func newTestGeoTIFFTile(t *testing.T, g testGeoTIFF, options ...GeoTIFFTileOption) *GeoTIFFTile {
+	t.Helper()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "test.tif"), g.bytes(), 0o666))
+	geoTIFFTile, err := NewGeoTIFFTile(os.DirFS(dir), "test.tif", options...)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, geoTIFFTile.Close())
+	})
+	return geoTIFFTile
+}
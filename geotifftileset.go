@@ -3,36 +3,49 @@ package elevation
 // FIXME it's rasters all the way down: instead of TileSet/GeoTIFFTile/Tile make it a hierarchy
 // FIXME rasters all implement Sample() and Samples() and pass this down the tree if needed
 // FIXME need to add an interface method to group related samples together
-// FIXME check possible off-by-one error compared to QGIS
 // FIXME interpolation
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"math"
+	"strconv"
 
 	"github.com/maypok86/otter/v2"
+	"github.com/twpayne/go-proj/v11"
 )
 
+var errNoFloat64TileCoordFunc = errors.New("no float64 tile coord func")
+
+// ErrCRSMismatch is returned when a tile's CRS does not match its tile set's
+// SRID.
+var ErrCRSMismatch = errors.New("CRS mismatch")
+
 // A TileCoordFunc returns the tile coordinate for a coordinate.
 type TileCoordFunc func(Coord) (TileCoord, bool)
 
+// A Float64TileCoordFunc returns the tile coordinate for a floating point
+// coordinate.
+type Float64TileCoordFunc func(Float64Coord) (TileCoord, bool)
+
 // A TileFilenameFunc returns the tile filename for a tile coordinate.
 type TileFilenameFunc func(TileCoord) string
 
 // A GeoTIFFTileSet is a set of GeoTIFF tiles.
 type GeoTIFFTileSet struct {
-	fsys               fs.FS
-	canaryFilename     string
-	srid               int
-	tileCoordFunc      TileCoordFunc
-	tileFilenameFunc   TileFilenameFunc
-	geoTIFFTileOptions []GeoTIFFTileOption
-	cacheSize          int
-	scaleX             int
-	scaleY             int
-	geoTIFFTileCache   *otter.Cache[TileCoord, *GeoTIFFTile]
+	fsys                 fs.FS
+	canaryFilename       string
+	srid                 int
+	tileCoordFunc        TileCoordFunc
+	float64TileCoordFunc Float64TileCoordFunc
+	tileFilenameFunc     TileFilenameFunc
+	geoTIFFTileOptions   []GeoTIFFTileOption
+	cacheSize            int
+	scaleX               int
+	scaleY               int
+	geoTIFFTileCache     *otter.Cache[TileCoord, *GeoTIFFTile]
 }
 
 // A GeoTIFFTileSetOption sets an option on a GeoTIFFTileSet.
@@ -101,6 +114,17 @@ func WithTileCoordFunc(tileCoordFunc TileCoordFunc) GeoTIFFTileSetOption {
 	}
 }
 
+// WithFloat64TileCoordFunc sets the function used to find the tile containing
+// a floating point coordinate.
+func WithFloat64TileCoordFunc(float64TileCoordFunc Float64TileCoordFunc) GeoTIFFTileSetOption {
+	return func(s *GeoTIFFTileSet) {
+		s.float64TileCoordFunc = float64TileCoordFunc
+	}
+}
+
+// WithSRID sets the EPSG code of the tile set's CRS. Sampling a tile whose CRS
+// does not match it returns [ErrCRSMismatch]. Tiles with user-defined CRSs,
+// like EU-DEM's, are compared using [ParsedGeoKeys.PROJString].
 func WithSRID(srid int) GeoTIFFTileSetOption {
 	return func(s *GeoTIFFTileSet) {
 		s.srid = srid
@@ -123,36 +147,174 @@ func WithTileFilenameFunc(tileFilenameFunc TileFilenameFunc) GeoTIFFTileSetOptio
 // Samples returns the samples at coords. Missing samples are represented by
 // NaNs.
 func (s *GeoTIFFTileSet) Samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	return tileSetSamples(ctx, s.getTileCached, coords, s.tileCoordFunc, releaseAfter((*GeoTIFFTile).samples))
+}
+
+// SamplesFloat64 returns the samples at floating point coords. Missing samples
+// are represented by NaNs.
+func (s *GeoTIFFTileSet) SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	if s.float64TileCoordFunc == nil {
+		return nil, errNoFloat64TileCoordFunc
+	}
+	return tileSetSamples(ctx, s.getTileCached, coords, s.float64TileCoordFunc, releaseAfter((*GeoTIFFTile).samplesFloat64))
+}
+
+// SRID returns s's SRID.
+func (s *GeoTIFFTileSet) SRID() int {
+	return s.srid
+}
+
+// Scale returns s's scale, or zero if it has none.
+func (s *GeoTIFFTileSet) Scale() (int, int) {
+	return s.scaleX, s.scaleY
+}
+
+// getTile returns the tile at the given tile coordinate. If s has an SRID then
+// the tile's CRS must match it.
+func (s *GeoTIFFTileSet) getTile(ctx context.Context, tileCoord TileCoord) (*GeoTIFFTile, error) {
+	filename := s.tileFilenameFunc(tileCoord)
+	geoTIFFTile, err := NewGeoTIFFTile(s.fsys, filename, s.geoTIFFTileOptions...)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, otter.ErrNotFound
+	case err != nil:
+		return nil, err
+	}
+	if err := s.checkCRS(geoTIFFTile); err != nil {
+		_ = geoTIFFTile.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return geoTIFFTile, nil
+}
+
+// checkCRS returns an error if geoTIFFTile's CRS does not match s's SRID. CRSs
+// with an EPSG code are compared by their code. User-defined CRSs, like
+// EU-DEM's, are compared by transforming the corners and center of
+// geoTIFFTile to s's SRID with PROJ, which must move them by less than a
+// hundredth of a pixel. See [ParsedGeoKeys.PROJString] for how their datums are
+// handled. Tiles without GeoKeys are assumed to match.
+func (s *GeoTIFFTileSet) checkCRS(geoTIFFTile *GeoTIFFTile) error {
+	if s.srid == 0 || geoTIFFTile.GeoKeys() == nil {
+		return nil
+	}
+	if epsg, ok := geoTIFFTile.EPSG(); ok {
+		if epsg != s.srid {
+			return fmt.Errorf("EPSG:%d does not match SRID %d: %w", epsg, s.srid, ErrCRSMismatch)
+		}
+		return nil
+	}
+
+	projString, err := geoTIFFTile.GeoKeys().PROJString()
+	if err != nil {
+		return fmt.Errorf("user-defined CRS: %w", err)
+	}
+	pj, err := proj.NewCRSToCRS(projString, "EPSG:"+strconv.Itoa(s.srid), nil)
+	if err != nil {
+		return err
+	}
+	// GeoTIFF coordinates are always easting, northing or longitude,
+	// latitude.
+	pj, err = pj.NormalizeForVisualization()
+	if err != nil {
+		return err
+	}
+
+	width, length := geoTIFFTile.Size()
+	var coords, expectedCoords [][]float64
+	for _, pixel := range [][2]float64{
+		{0, 0},
+		{float64(width), 0},
+		{0, float64(length)},
+		{float64(width), float64(length)},
+		{float64(width) / 2, float64(length) / 2},
+	} {
+		x, y := geoTIFFTile.transform.apply(pixel[0], pixel[1])
+		coords = append(coords, []float64{x, y})
+		expectedCoords = append(expectedCoords, []float64{x, y})
+	}
+	if err := pj.ForwardFloat64Slices(coords); err != nil {
+		return err
+	}
+	pixelScaleX, pixelScaleY := geoTIFFTile.PixelScale()
+	tolerance := min(pixelScaleX, pixelScaleY) / 100
+	for i, coord := range coords {
+		if !(math.Abs(coord[0]-expectedCoords[i][0]) <= tolerance && math.Abs(coord[1]-expectedCoords[i][1]) <= tolerance) {
+			return fmt.Errorf("%s does not match SRID %d: %w", projString, s.srid, ErrCRSMismatch)
+		}
+	}
+	return nil
+}
+
+// getTileCached returns the tile at the give tile coordinate, using the cache
+// if possible. It adds a reference to the tile's file so that the tile is not
+// closed while it is in use if it is evicted from the cache. The caller must
+// release the reference.
+func (s *GeoTIFFTileSet) getTileCached(ctx context.Context, tileCoord TileCoord) (*GeoTIFFTile, error) {
+	for {
+		geoTIFFTile, err := s.geoTIFFTileCache.Get(ctx, tileCoord, otter.LoaderFunc[TileCoord, *GeoTIFFTile](s.getTile))
+		if err != nil {
+			return nil, err
+		}
+		// If the tile was evicted and closed after it was returned by the
+		// cache then get it again.
+		if geoTIFFTile.file.acquire() {
+			return geoTIFFTile, nil
+		}
+	}
+}
+
+// A sharedFileTile is a tile whose file is a sharedFile.
+type sharedFileTile interface {
+	sharedTileFile() *sharedFile
+}
+
+// releaseAfter returns a function that calls samplesFunc and then releases
+// the reference to the tile's file added by getTileCached. samplesFunc must not
+// add another reference, as it would fail if the tile was evicted and closed
+// after getTileCached returned it.
+func releaseAfter[T sharedFileTile, C any](
+	samplesFunc func(T, context.Context, []C) ([]float64, error),
+) func(T, context.Context, []C) ([]float64, error) {
+	return func(tile T, ctx context.Context, coords []C) ([]float64, error) {
+		defer tile.sharedTileFile().release()
+		return samplesFunc(tile, ctx, coords)
+	}
+}
+
+// tileSetSamples returns the samples at coords from a tile set, using
+// tileCoordFunc to find the tile containing each coord, getTileFunc to get each
+// tile, and samplesFunc to sample each tile. getTileFunc returns
+// otter.ErrNotFound for missing tiles.
+func tileSetSamples[T, C any](
+	ctx context.Context,
+	getTileFunc func(context.Context, TileCoord) (T, error),
+	coords []C,
+	tileCoordFunc func(C) (TileCoord, bool),
+	samplesFunc func(T, context.Context, []C) ([]float64, error),
+) ([]float64, error) {
 	samples := make([]float64, len(coords))
 
 	// Group indexes by tile coord.
 	type groupStruct struct {
-		coords  []Coord
+		coords  []C
 		indexes []int
 	}
 	groupsByTileCoord := make(map[TileCoord]groupStruct)
 	for index, coord := range coords {
-		tileCoord, ok := s.tileCoordFunc(coord)
+		tileCoord, ok := tileCoordFunc(coord)
 		if !ok {
 			samples[index] = math.NaN()
 			continue
 		}
-		if group, ok := groupsByTileCoord[tileCoord]; ok {
-			group.coords = append(group.coords, coord)
-			group.indexes = append(group.indexes, index)
-			groupsByTileCoord[tileCoord] = group
-		} else {
-			group := groupStruct{
-				coords:  []Coord{coord},
-				indexes: []int{index},
-			}
-			groupsByTileCoord[tileCoord] = group
-		}
+		group := groupsByTileCoord[tileCoord]
+		group.coords = append(group.coords, coord)
+		group.indexes = append(group.indexes, index)
+		groupsByTileCoord[tileCoord] = group
 	}
 
 	// Populate samples one tile at a time.
 	for tileCoord, group := range groupsByTileCoord {
-		switch tile, err := s.getTileCached(ctx, tileCoord); {
+		switch tile, err := getTileFunc(ctx, tileCoord); {
 		case errors.Is(err, otter.ErrNotFound):
 			for _, index := range group.indexes {
 				samples[index] = math.NaN()
@@ -160,7 +322,7 @@ func (s *GeoTIFFTileSet) Samples(ctx context.Context, coords []Coord) ([]float64
 		case err != nil:
 			return nil, err
 		default:
-			localSamples, err := tile.Samples(ctx, group.coords)
+			localSamples, err := samplesFunc(tile, ctx, group.coords)
 			if err != nil {
 				return nil, err
 			}
@@ -172,32 +334,3 @@ func (s *GeoTIFFTileSet) Samples(ctx context.Context, coords []Coord) ([]float64
 
 	return samples, nil
 }
-
-// SRID returns s's SRID.
-func (s *GeoTIFFTileSet) SRID() int {
-	return s.srid
-}
-
-// Scale returns s's scale.
-func (s *GeoTIFFTileSet) Scale() (int, int) {
-	return s.scaleX, s.scaleY
-}
-
-// getTile returns the tile at the given tile coordinate.
-func (s *GeoTIFFTileSet) getTile(ctx context.Context, tileCoord TileCoord) (*GeoTIFFTile, error) {
-	filename := s.tileFilenameFunc(tileCoord)
-	switch geoTIFFTile, err := NewGeoTIFFTile(s.fsys, filename, s.geoTIFFTileOptions...); {
-	case errors.Is(err, fs.ErrNotExist):
-		return nil, otter.ErrNotFound
-	case err != nil:
-		return nil, err
-	default:
-		return geoTIFFTile, nil
-	}
-}
-
-// getTileCached returns the tile at the give tile coordinate, using the cache
-// if possible.
-func (s *GeoTIFFTileSet) getTileCached(ctx context.Context, tileCoord TileCoord) (*GeoTIFFTile, error) {
-	return s.geoTIFFTileCache.Get(ctx, tileCoord, otter.LoaderFunc[TileCoord, *GeoTIFFTile](s.getTile))
-}
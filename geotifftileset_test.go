@@ -1,3 +1,323 @@
 package elevation
 
-var _ Raster = &GeoTIFFTileSet{}
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+var (
+	_ Raster        = &GeoTIFFTileSet{}
+	_ Float64Raster = &GeoTIFFTile{}
+	_ Float64Raster = &GeoTIFFTileSet{}
+)
+
+func TestGeoTIFFTileSet_SamplesFloat64(t *testing.T) {
+	// Create two one degree tiles at 6E 47N and 7E 47N with 100 pixels per
+	// degree.
+	dir := t.TempDir()
+	gs := make([]testGeoTIFF, 2)
+	for i := range gs {
+		g := newTestGeoTIFF()
+		g.imageWidth = 100
+		g.imageLength = 100
+		g.tileWidth = 64
+		g.tileLength = 64
+		g.pixelScale = []float64{0.01, 0.01, 0}
+		g.tiepoint = []float64{0, 0, 0, float64(6 + i), 48, 0}
+		g.sample = func(x, y int) float64 {
+			return float64(1000*i + x + 100*y)
+		}
+		g.isNoData = func(x, y int) bool {
+			return false
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, strconv.Itoa(6+i)+".tif"), g.bytes(), 0o666))
+		gs[i] = g
+	}
+
+	geoTIFFTileSet, err := NewGeoTIFFTileSet(
+		WithFS(os.DirFS(dir)),
+		WithFloat64TileCoordFunc(func(coord Float64Coord) (TileCoord, bool) {
+			if coord.Y < 47 || 48 <= coord.Y {
+				return TileCoord{}, false
+			}
+			return TileCoord{C: int(math.Floor(coord.X))}, true
+		}),
+		WithTileFilenameFunc(func(tileCoord TileCoord) string {
+			return strconv.Itoa(tileCoord.C) + ".tif"
+		}),
+	)
+	assert.NoError(t, err)
+
+	actual, err := geoTIFFTileSet.SamplesFloat64(t.Context(), []Float64Coord{
+		{X: 6.005, Y: 47.995},
+		{X: 7.005, Y: 47.995},
+		{X: 6.995, Y: 47.005},
+		{X: 7.125, Y: 47.505},
+		{X: 8.5, Y: 47.5},
+		{X: 6.5, Y: 48.5},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{
+		gs[0].sample(0, 0),
+		gs[1].sample(0, 0),
+		gs[0].sample(99, 99),
+		gs[1].sample(12, 49),
+		math.NaN(),
+		math.NaN(),
+	}, actual)
+}
+
+func TestGeoTIFFTileSet_ConcurrentEviction(t *testing.T) {
+	// Create four uncompressed one degree tiles at 6E to 9E with 100 pixels
+	// per degree, so samples are read directly from the mapped files.
+	dir := t.TempDir()
+	for i := range 4 {
+		g := newTestGeoTIFF()
+		g.compression = CompressionNone
+		g.compress = slices.Clone[[]byte]
+		g.imageWidth = 100
+		g.imageLength = 100
+		g.pixelScale = []float64{0.01, 0.01, 0}
+		g.tiepoint = []float64{0, 0, 0, float64(6 + i), 48, 0}
+		g.sample = func(x, y int) float64 {
+			return float64(1000*i + x + 100*y)
+		}
+		g.isNoData = func(x, y int) bool {
+			return false
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, strconv.Itoa(6+i)+".tif"), g.bytes(), 0o666))
+	}
+
+	// With a cache size of one, tiles are evicted and closed while other
+	// goroutines are sampling them.
+	geoTIFFTileSet, err := NewGeoTIFFTileSet(
+		WithCacheSize(1),
+		WithFS(os.DirFS(dir)),
+		WithFloat64TileCoordFunc(func(coord Float64Coord) (TileCoord, bool) {
+			return TileCoord{C: int(math.Floor(coord.X))}, true
+		}),
+		WithGeoTIFFTileOptions(
+			WithMmap(true),
+		),
+		WithTileFilenameFunc(func(tileCoord TileCoord) string {
+			return strconv.Itoa(tileCoord.C) + ".tif"
+		}),
+	)
+	assert.NoError(t, err)
+
+	// Sample every pixel in each tile, so that reads are in progress when
+	// tiles are evicted.
+	coords := make([][]Float64Coord, 4)
+	expected := make([][]float64, 4)
+	for i := range 4 {
+		for y := range 100 {
+			for x := range 100 {
+				coords[i] = append(coords[i], Float64Coord{X: float64(6+i) + (float64(x)+0.5)/100, Y: 48 - (float64(y)+0.5)/100})
+				expected[i] = append(expected[i], float64(1000*i+x+100*y))
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				k := (i + j) % 4
+				actual, err := geoTIFFTileSet.SamplesFloat64(t.Context(), coords[k])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !slices.Equal(expected[k], actual) {
+					t.Errorf("tile %d: unexpected samples", k)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGeoTIFFTileSet_NoFloat64TileCoordFunc(t *testing.T) {
+	geoTIFFTileSet, err := NewGeoTIFFTileSet()
+	assert.NoError(t, err)
+	_, err = geoTIFFTileSet.SamplesFloat64(t.Context(), []Float64Coord{{X: 6, Y: 47}})
+	assert.IsError(t, err, errNoFloat64TileCoordFunc)
+}
+
+func TestGeoTIFFTileSet_SRID(t *testing.T) {
+	dir := t.TempDir()
+	for filename, geoKeys := range map[string][]uint16{
+		"3035.tif": {
+			1, 1, 0, 2,
+			1024, 0, 1, ModelTypeProjected,
+			3072, 0, 1, 3035,
+		},
+		"4326.tif": {
+			1, 1, 0, 2,
+			1024, 0, 1, ModelTypeGeographic,
+			2048, 0, 1, 4326,
+		},
+		"none.tif": nil,
+		// A user-defined CRS with an unsupported projection method, Mercator,
+		// which cannot be compared with the SRID.
+		"unsupported.tif": {
+			1, 1, 0, 5,
+			1024, 0, 1, ModelTypeProjected,
+			2056, 0, 1, 7030,
+			3072, 0, 1, 32767,
+			3075, 0, 1, 7,
+			3076, 0, 1, LinearUnitMetre,
+		},
+	} {
+		g := newTestGeoTIFF()
+		g.geoKeys = geoKeys
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, filename), g.bytes(), 0o666))
+	}
+	filenames := []string{"3035.tif", "4326.tif", "none.tif", "unsupported.tif"}
+
+	for _, tc := range []struct {
+		name           string
+		srid           int
+		expectedErrors []error
+	}{
+		{
+			name:           "none",
+			expectedErrors: []error{nil, nil, nil, nil},
+		},
+		{
+			name:           "3035",
+			srid:           3035,
+			expectedErrors: []error{nil, ErrCRSMismatch, nil, errors.ErrUnsupported},
+		},
+		{
+			name:           "4326",
+			srid:           4326,
+			expectedErrors: []error{ErrCRSMismatch, nil, nil, errors.ErrUnsupported},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			geoTIFFTileSet, err := NewGeoTIFFTileSet(
+				WithFS(os.DirFS(dir)),
+				WithSRID(tc.srid),
+				WithTileCoordFunc(func(coord Coord) (TileCoord, bool) {
+					return TileCoord{C: coord.X}, true
+				}),
+				WithTileFilenameFunc(func(tileCoord TileCoord) string {
+					return filenames[tileCoord.C]
+				}),
+			)
+			assert.NoError(t, err)
+
+			for i, expectedErr := range tc.expectedErrors {
+				_, err := geoTIFFTileSet.Samples(t.Context(), []Coord{{X: i}})
+				if expectedErr == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.IsError(t, err, expectedErr)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoTIFFTileSet_SRIDUserDefined(t *testing.T) {
+	dir := t.TempDir()
+	for filename, g := range map[string]testGeoTIFF{
+		// The user-defined EPSG:3035 GeoKeys written by ArcGIS, as used by
+		// EU-DEM.
+		"laea.tif": func() testGeoTIFF {
+			g := newTestGeoTIFF()
+			g.pixelScale = []float64{25, 25, 0}
+			g.tiepoint = []float64{0, 0, 0, 4000000, 3000000, 0}
+			g.geoKeys = []uint16{
+				1, 1, 0, 11,
+				1024, 0, 1, ModelTypeProjected,
+				2048, 0, 1, 4258,
+				2054, 0, 1, 9102,
+				2056, 0, 1, 7019,
+				3072, 0, 1, 32767,
+				3075, 0, 1, CTLambertAzimEqualArea,
+				3076, 0, 1, LinearUnitMetre,
+				3082, 34736, 1, 2,
+				3083, 34736, 1, 3,
+				3088, 34736, 1, 1,
+				3089, 34736, 1, 0,
+			}
+			g.geoDoubleParams = []float64{52, 10, 4321000, 3210000}
+			return g
+		}(),
+		"longlat.tif": func() testGeoTIFF {
+			g := newTestGeoTIFF()
+			g.pixelScale = []float64{0.001, 0.001, 0}
+			g.tiepoint = []float64{0, 0, 0, 7, 47, 0}
+			g.geoKeys = []uint16{
+				1, 1, 0, 3,
+				1024, 0, 1, ModelTypeGeographic,
+				2048, 0, 1, 32767,
+				2056, 0, 1, 7030,
+			}
+			return g
+		}(),
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, filename), g.bytes(), 0o666))
+	}
+	filenames := []string{"laea.tif", "longlat.tif"}
+
+	for _, tc := range []struct {
+		name           string
+		srid           int
+		expectedErrors []error
+	}{
+		{
+			name:           "3035",
+			srid:           3035,
+			expectedErrors: []error{nil, ErrCRSMismatch},
+		},
+		{
+			// ETRS89 / LCC Europe, which has the same datum as EPSG:3035 but
+			// a different projection.
+			name:           "3034",
+			srid:           3034,
+			expectedErrors: []error{ErrCRSMismatch, ErrCRSMismatch},
+		},
+		{
+			name:           "4326",
+			srid:           4326,
+			expectedErrors: []error{ErrCRSMismatch, nil},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			geoTIFFTileSet, err := NewGeoTIFFTileSet(
+				WithFS(os.DirFS(dir)),
+				WithSRID(tc.srid),
+				WithTileCoordFunc(func(coord Coord) (TileCoord, bool) {
+					return TileCoord{C: coord.X}, true
+				}),
+				WithTileFilenameFunc(func(tileCoord TileCoord) string {
+					return filenames[tileCoord.C]
+				}),
+			)
+			assert.NoError(t, err)
+
+			for i, expectedErr := range tc.expectedErrors {
+				_, err := geoTIFFTileSet.Samples(t.Context(), []Coord{{X: i}})
+				if expectedErr == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.IsError(t, err, expectedErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package elevation
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A GeoTIFFWriterOption sets an option on a GeoTIFF writer.
+type GeoTIFFWriterOption func(*geoTIFFWriter)
+
+// A geoTIFFWriter writes rasters as GeoTIFFs.
+type geoTIFFWriter struct {
+	bigTIFF      bool
+	compression  int
+	compressFunc compressFunc
+	modelType    int
+	noData       float64
+	predictor    int
+	resampling   int
+	srid         int
+	tileSize     int
+}
+
+// WithWriterBigTIFF sets whether to write a BigTIFF file. BigTIFF files are
+// always written if the file would be too large for TIFF.
+func WithWriterBigTIFF(bigTIFF bool) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.bigTIFF = bigTIFF
+	}
+}
+
+// WithWriterCompression sets the compression scheme, either
+// [CompressionNone], [CompressionDeflate] (the default),
+// [CompressionAdobeDeflate], or [CompressionZSTD].
+func WithWriterCompression(compression int) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.compression = compression
+	}
+}
+
+// WithWriterModelType sets the model type of the CRS, either
+// [ModelTypeProjected] (the default) or [ModelTypeGeographic]. It determines
+// whether the SRID is written as a projected or a geographic CRS. Rasters in
+// degrees have no scale and cannot be written, so the CRS is normally
+// projected.
+func WithWriterModelType(modelType int) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.modelType = modelType
+	}
+}
+
+// WithWriterNoData sets the nodata value written in place of NaN samples. The
+// default is -math.MaxFloat32, as used by EU-DEM.
+func WithWriterNoData(noData float64) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.noData = noData
+	}
+}
+
+// WithWriterSRID sets the EPSG code of the CRS, overriding the raster's SRID.
+// Geographic CRSs also need [WithWriterModelType].
+func WithWriterSRID(srid int) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.srid = srid
+	}
+}
+
+// WithWriterTileSize sets the width and length of tiles in pixels. It must be
+// a multiple of 16. The default is 256.
+func WithWriterTileSize(tileSize int) GeoTIFFWriterOption {
+	return func(w *geoTIFFWriter) {
+		w.tileSize = tileSize
+	}
+}
+
+// WriteGeoTIFF writes the samples of raster within bounds to w as a tiled,
+// compressed, float32 GeoTIFF. bounds is expanded to whole pixels of raster's
+// scale and each pixel is sampled at its center, using
+// [Float64Raster.SamplesFloat64] if raster supports it so that the center is
+// exact for odd scales. NaN samples are written as
+// the nodata value. If raster has an SRID method, for example a
+// [GeoTIFFTileSet], then its SRID is written as the CRS.
+func WriteGeoTIFF(ctx context.Context, w io.Writer, raster Raster, bounds Bounds, options ...GeoTIFFWriterOption) error {
+	gw, err := newGeoTIFFWriter(raster, options...)
+	if err != nil {
+		return err
+	}
+	grid, err := newWriterGrid(raster, bounds)
+	if err != nil {
+		return err
+	}
+	fields, err := gw.fields(grid.width, grid.length, grid)
+	if err != nil {
+		return err
+	}
+	tiles, err := gw.encodeTiles(ctx, grid.width, grid.length, func(x0, y0, x1, y1 int) ([]float32, error) {
+		return grid.samples(ctx, raster, x0, y0, x1, y1)
+	})
+	if err != nil {
+		return err
+	}
+	return gw.encode(w, []*tiffImage{{fields: fields, tiles: tiles}}, nil)
+}
+
+// A writerGrid is the grid of pixels written from a raster.
+type writerGrid struct {
+	minX   int
+	maxY   int
+	scaleX int
+	scaleY int
+	width  int
+	length int
+}
+
+// newWriterGrid returns the grid of pixels of raster covering bounds.
+func newWriterGrid(raster Raster, bounds Bounds) (*writerGrid, error) {
+	scaleX, scaleY := raster.Scale()
+	if scaleX <= 0 || scaleY <= 0 {
+		return nil, fmt.Errorf("scale %d, %d: %w", scaleX, scaleY, ErrNoScale)
+	}
+	minX := scaleX * floorDiv(bounds.Min.X, scaleX)
+	minY := scaleY * floorDiv(bounds.Min.Y, scaleY)
+	maxX := -scaleX * floorDiv(-bounds.Max.X, scaleX)
+	maxY := -scaleY * floorDiv(-bounds.Max.Y, scaleY)
+	width := (maxX - minX) / scaleX
+	length := (maxY - minY) / scaleY
+	if width <= 0 || length <= 0 {
+		return nil, fmt.Errorf("%v: empty bounds", bounds)
+	}
+	if uint64(width) > math.MaxUint32 || uint64(length) > math.MaxUint32 {
+		return nil, fmt.Errorf("%dx%d: %w", width, length, errors.ErrUnsupported)
+	}
+	return &writerGrid{
+		minX:   minX,
+		maxY:   maxY,
+		scaleX: scaleX,
+		scaleY: scaleY,
+		width:  width,
+		length: length,
+	}, nil
+}
+
+// samples returns the samples of raster at the center of each pixel of g in
+// [x0, x1) x [y0, y1), in row-major order. Rasters that cannot be sampled at
+// floating point coordinates, like GeoTIFFTileSets without a
+// Float64TileCoordFunc, are sampled at the integer coordinate nearest to the
+// center, rounding down.
+func (g *writerGrid) samples(ctx context.Context, raster Raster, x0, y0, x1, y1 int) ([]float32, error) {
+	float64Coords := make([]Float64Coord, 0, (x1-x0)*(y1-y0))
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			float64Coords = append(float64Coords, Float64Coord{
+				X: float64(g.minX) + (float64(x)+0.5)*float64(g.scaleX),
+				Y: float64(g.maxY) - (float64(y)+0.5)*float64(g.scaleY),
+			})
+		}
+	}
+
+	var samples []float64
+	err := errNoFloat64TileCoordFunc
+	if float64Raster, ok := raster.(Float64Raster); ok {
+		samples, err = float64Raster.SamplesFloat64(ctx, float64Coords)
+	}
+	if errors.Is(err, errNoFloat64TileCoordFunc) {
+		coords := make([]Coord, len(float64Coords))
+		for i, float64Coord := range float64Coords {
+			coords[i] = Coord{
+				X: int(math.Floor(float64Coord.X)),
+				Y: int(math.Floor(float64Coord.Y)),
+			}
+		}
+		samples, err = raster.Samples(ctx, coords)
+	}
+	if err != nil {
+		return nil, err
+	}
+	float32Samples := make([]float32, len(samples))
+	for i, sample := range samples {
+		float32Samples[i] = float32(sample)
+	}
+	return float32Samples, nil
+}
+
+// newGeoTIFFWriter returns a new geoTIFFWriter for raster with options.
+func newGeoTIFFWriter(raster Raster, options ...GeoTIFFWriterOption) (*geoTIFFWriter, error) {
+	gw := &geoTIFFWriter{
+		compression: CompressionDeflate,
+		modelType:   ModelTypeProjected,
+		noData:      -math.MaxFloat32,
+		tileSize:    256,
+	}
+	if sridRaster, ok := raster.(interface{ SRID() int }); ok {
+		gw.srid = sridRaster.SRID()
+	}
+	for _, option := range options {
+		option(gw)
+	}
+	if gw.modelType != ModelTypeProjected && gw.modelType != ModelTypeGeographic {
+		return nil, fmt.Errorf("model type %d: %w", gw.modelType, errors.ErrUnsupported)
+	}
+	if gw.tileSize <= 0 || gw.tileSize%16 != 0 {
+		return nil, fmt.Errorf("tile size %d: not a positive multiple of 16", gw.tileSize)
+	}
+	var ok bool
+	gw.compressFunc, ok = defaultCompressFuncs[gw.compression]
+	if !ok {
+		return nil, fmt.Errorf("compression %d: %w", gw.compression, errors.ErrUnsupported)
+	}
+	gw.predictor = predictorNone
+	if gw.compression != CompressionNone {
+		gw.predictor = predictorFloatingPoint
+	}
+	// Store the nodata value as a float32, so that it matches the samples.
+	gw.noData = float64(float32(gw.noData))
+	return gw, nil
+}
+
+// fields returns the TIFF fields of an image of width by length pixels. If
+// grid is nil then the image is a reduced resolution overview, otherwise it is
+// the full resolution image georeferenced by grid.
+func (gw *geoTIFFWriter) fields(width, length int, grid *writerGrid) ([]tiffField, error) {
+	fields := []tiffField{
+		{tag: tiffTagImageWidth, value: []uint32{uint32(width)}},
+		{tag: tiffTagImageLength, value: []uint32{uint32(length)}},
+		{tag: tiffTagBitsPerSample, value: []uint16{32}},
+		{tag: tiffTagCompression, value: []uint16{uint16(gw.compression)}},
+		{tag: tiffTagPhotometricInterpretation, value: []uint16{1}}, // BlackIsZero.
+		{tag: tiffTagSamplesPerPixel, value: []uint16{1}},
+		{tag: tiffTagPlanarConfiguration, value: []uint16{planarConfigurationChunky}},
+		{tag: tiffTagPredictor, value: []uint16{uint16(gw.predictor)}},
+		{tag: tiffTagTileWidth, value: []uint32{uint32(gw.tileSize)}},
+		{tag: tiffTagTileLength, value: []uint32{uint32(gw.tileSize)}},
+		{tag: tiffTagSampleFormat, value: []uint16{sampleFormatIEEEFP}},
+		{tag: tiffTagGDALNoData, value: strconv.FormatFloat(gw.noData, 'g', -1, 64)},
+	}
+	if grid == nil {
+		fields = append(fields, tiffField{tag: tiffTagNewSubfileType, value: []uint32{newSubfileTypeReducedResolution}})
+		return fields, nil
+	}
+	fields = append(fields,
+		tiffField{tag: tiffTagModelPixelScale, value: []float64{float64(grid.scaleX), float64(grid.scaleY), 0}},
+		tiffField{tag: tiffTagModelTiepoint, value: []float64{0, 0, 0, float64(grid.minX), float64(grid.maxY), 0}},
+	)
+	if gw.srid != 0 {
+		geoKeyDirectory, err := newGeoKeyDirectory(gw.srid, gw.modelType)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, tiffField{tag: tiffTagGeoKeyDirectory, value: geoKeyDirectory})
+	}
+	return fields, nil
+}
+
+// encodeTiles returns the compressed tiles of an image of width by length
+// pixels. tileSamplesFunc returns the samples in [x0, x1) x [y0, y1) in
+// row-major order, with NaN for nodata.
+func (gw *geoTIFFWriter) encodeTiles(ctx context.Context, width, length int, tileSamplesFunc func(x0, y0, x1, y1 int) ([]float32, error)) ([][]byte, error) {
+	tilesAcross := (width + gw.tileSize - 1) / gw.tileSize
+	tilesDown := (length + gw.tileSize - 1) / gw.tileSize
+	tiles := make([][]byte, 0, tilesAcross*tilesDown)
+	tileData := make([]byte, 4*gw.tileSize*gw.tileSize)
+	for tileRow := range tilesDown {
+		for tileColumn := range tilesAcross {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			x0, y0 := tileColumn*gw.tileSize, tileRow*gw.tileSize
+			x1, y1 := min(x0+gw.tileSize, width), min(y0+gw.tileSize, length)
+			samples, err := tileSamplesFunc(x0, y0, x1, y1)
+			if err != nil {
+				return nil, err
+			}
+
+			// Pixels outside the image are padded with nodata.
+			i := 0
+			for y := range gw.tileSize {
+				for x := range gw.tileSize {
+					sample := float32(gw.noData)
+					if x0+x < x1 && y0+y < y1 {
+						if !math.IsNaN(float64(samples[i])) {
+							sample = samples[i]
+						}
+						i++
+					}
+					binary.LittleEndian.PutUint32(tileData[4*(y*gw.tileSize+x):], math.Float32bits(sample))
+				}
+			}
+
+			data := tileData
+			if gw.predictor == predictorFloatingPoint {
+				data = applyFloatingPointPredictor(tileData, gw.tileSize, 1, 4, binary.LittleEndian)
+			}
+			compressedData, err := gw.compressFunc(data)
+			if err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, compressedData)
+		}
+	}
+	return tiles, nil
+}
+
+// encode writes images and ghostArea to w, as a BigTIFF file if needed.
+func (gw *geoTIFFWriter) encode(w io.Writer, images []*tiffImage, ghostArea []byte) error {
+	bigTIFF := gw.bigTIFF
+	if !bigTIFF {
+		// Switch to BigTIFF if the tile data alone is too large for TIFF. The
+		// IFDs are small in comparison.
+		size := uint64(0)
+		for _, image := range images {
+			for _, tile := range image.tiles {
+				size += uint64(len(tile))
+			}
+		}
+		bigTIFF = size > math.MaxUint32-1<<20
+	}
+	return encodeTIFF(w, images, bigTIFF, ghostArea)
+}
+
+// newGeoKeyDirectory returns a GeoKeyDirectoryTag value for the CRS with EPSG
+// code srid and modelType.
+func newGeoKeyDirectory(srid, modelType int) ([]uint16, error) {
+	if srid <= 0 || srid >= geoKeyUserDefined {
+		return nil, fmt.Errorf("SRID %d: %w", srid, errors.ErrUnsupported)
+	}
+	crsGeoKey := GeoKeyProjectedCRS
+	if modelType == ModelTypeGeographic {
+		crsGeoKey = GeoKeyGeodeticCRS
+	}
+	return []uint16{
+		1, 1, 0, 3,
+		uint16(GeoKeyGTModelType), 0, 1, uint16(modelType),
+		uint16(GeoKeyGTRasterType), 0, 1, RasterPixelIsArea,
+		uint16(crsGeoKey), 0, 1, uint16(srid),
+	}, nil
+}
@@ -0,0 +1,325 @@
+package elevation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// A testFuncRaster is a Raster whose samples are returned by a function.
+type testFuncRaster struct {
+	scaleX int
+	scaleY int
+	srid   int
+	sample func(Coord) float64
+}
+
+func (r *testFuncRaster) Samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	samples := make([]float64, len(coords))
+	for i, coord := range coords {
+		samples[i] = r.sample(coord)
+	}
+	return samples, nil
+}
+
+func (r *testFuncRaster) Scale() (int, int) {
+	return r.scaleX, r.scaleY
+}
+
+func (r *testFuncRaster) SRID() int {
+	return r.srid
+}
+
+// A testFuncFloat64Raster is a testFuncRaster that can also be sampled at
+// floating point coordinates.
+type testFuncFloat64Raster struct {
+	testFuncRaster
+	sampleFloat64 func(Float64Coord) float64
+}
+
+func (r *testFuncFloat64Raster) SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	samples := make([]float64, len(coords))
+	for i, coord := range coords {
+		samples[i] = r.sampleFloat64(coord)
+	}
+	return samples, nil
+}
+
+func TestWriteGeoTIFF(t *testing.T) {
+	// Pixels are 25m wide and 50m long. Samples are NaN in a disc.
+	raster := &testFuncRaster{
+		scaleX: 25,
+		scaleY: 50,
+		srid:   3035,
+		sample: func(coord Coord) float64 {
+			x, y := coord.X/25, coord.Y/50
+			if (x-4050)*(x-4050)+(y-1450)*(y-1450) < 100 {
+				return math.NaN()
+			}
+			return float64(x + 1000*y%7919)
+		},
+	}
+	bounds := Bounds{
+		Min: Coord{X: 100000, Y: 70000},
+		Max: Coord{X: 104010, Y: 75990},
+	}
+
+	for _, tc := range []struct {
+		name              string
+		options           []GeoTIFFWriterOption
+		expectedPredictor int
+		expectedNoData    float64
+		expectedModelType int
+		expectedEPSG      int
+	}{
+		{
+			name:              "default",
+			expectedPredictor: predictorFloatingPoint,
+			expectedNoData:    -math.MaxFloat32,
+			expectedModelType: ModelTypeProjected,
+			expectedEPSG:      3035,
+		},
+		{
+			name: "none",
+			options: []GeoTIFFWriterOption{
+				WithWriterCompression(CompressionNone),
+			},
+			expectedPredictor: predictorNone,
+			expectedNoData:    -math.MaxFloat32,
+			expectedModelType: ModelTypeProjected,
+			expectedEPSG:      3035,
+		},
+		{
+			name: "geographic",
+			options: []GeoTIFFWriterOption{
+				WithWriterModelType(ModelTypeGeographic),
+				WithWriterSRID(4230),
+			},
+			expectedPredictor: predictorFloatingPoint,
+			expectedNoData:    -math.MaxFloat32,
+			expectedModelType: ModelTypeGeographic,
+			expectedEPSG:      4230,
+		},
+		{
+			name: "zstd_bigtiff",
+			options: []GeoTIFFWriterOption{
+				WithWriterBigTIFF(true),
+				WithWriterCompression(CompressionZSTD),
+				WithWriterNoData(-9999),
+				WithWriterSRID(25832),
+				WithWriterTileSize(64),
+			},
+			expectedPredictor: predictorFloatingPoint,
+			expectedNoData:    -9999,
+			expectedModelType: ModelTypeProjected,
+			expectedEPSG:      25832,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			assert.NoError(t, WriteGeoTIFF(t.Context(), &buffer, raster, bounds, tc.options...))
+			data := buffer.Bytes()
+
+			geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedPredictor, geoTIFFTile.predictor)
+			noData, ok := geoTIFFTile.NoData()
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedNoData, noData)
+			assert.Equal(t, tc.expectedModelType, geoTIFFTile.GeoKeys().Params[GeoKeyGTModelType])
+			epsg, ok := geoTIFFTile.EPSG()
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedEPSG, epsg)
+			assert.Equal(t, RasterPixelIsArea, geoTIFFTile.RasterType())
+
+			// The bounds are expanded to whole pixels.
+			width, length := geoTIFFTile.Size()
+			assert.Equal(t, 161, width)
+			assert.Equal(t, 120, length)
+			pixelScaleX, pixelScaleY := geoTIFFTile.PixelScale()
+			assert.Equal(t, 25.0, pixelScaleX)
+			assert.Equal(t, 50.0, pixelScaleY)
+
+			coords := make([]Coord, 0, width*length)
+			for y := range length {
+				for x := range width {
+					coords = append(coords, Coord{X: 100000 + 25*x + 12, Y: 76000 - 50*y - 25})
+				}
+			}
+			expected, err := raster.Samples(t.Context(), coords)
+			assert.NoError(t, err)
+			actual, err := geoTIFFTile.Samples(t.Context(), coords)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+
+			assert.True(t, math.IsNaN(actual[(76000-1450*50)/50*width+4050-4000]))
+			outside, err := geoTIFFTile.Samples(t.Context(), []Coord{{X: 99999, Y: 75000}, {X: 104025, Y: 75000}, {X: 102000, Y: 76001}})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{math.NaN(), math.NaN(), math.NaN()}, outside)
+		})
+	}
+}
+
+func TestWriteGeoTIFF_PixelCenters(t *testing.T) {
+	// Rasters that support floating point coordinates are sampled at the
+	// exact center of each pixel, even when the scale is odd.
+	raster := &testFuncFloat64Raster{
+		testFuncRaster: testFuncRaster{
+			scaleX: 25,
+			scaleY: 25,
+			srid:   3035,
+			sample: func(Coord) float64 {
+				return math.NaN()
+			},
+		},
+		sampleFloat64: func(coord Float64Coord) float64 {
+			return coord.X - 1000*coord.Y
+		},
+	}
+	var buffer bytes.Buffer
+	assert.NoError(t, WriteGeoTIFF(t.Context(), &buffer, raster, Bounds{
+		Min: Coord{X: 100, Y: 200},
+		Max: Coord{X: 150, Y: 250},
+	}))
+	data := buffer.Bytes()
+
+	geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	actual, err := geoTIFFTile.Samples(t.Context(), []Coord{
+		{X: 100, Y: 249},
+		{X: 149, Y: 249},
+		{X: 100, Y: 201},
+		{X: 149, Y: 201},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{
+		112.5 - 1000*237.5,
+		137.5 - 1000*237.5,
+		112.5 - 1000*212.5,
+		137.5 - 1000*212.5,
+	}, actual)
+}
+
+func TestWriteGeoTIFF_GeoTIFFTileSet(t *testing.T) {
+	g := newTestGeoTIFF()
+	g.geoKeys = []uint16{
+		1, 1, 0, 2,
+		1024, 0, 1, ModelTypeProjected,
+		3072, 0, 1, 3035,
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tile.tif"), g.bytes(), 0o666))
+	geoTIFFTileSet, err := NewGeoTIFFTileSet(
+		WithFS(os.DirFS(dir)),
+		WithSRID(3035),
+		WithScale(10, 10),
+		WithTileCoordFunc(func(coord Coord) (TileCoord, bool) {
+			return TileCoord{}, true
+		}),
+		WithTileFilenameFunc(func(tileCoord TileCoord) string {
+			return "tile.tif"
+		}),
+	)
+	assert.NoError(t, err)
+
+	// Write a subset that extends beyond the tile to the east.
+	var buffer bytes.Buffer
+	assert.NoError(t, WriteGeoTIFF(t.Context(), &buffer, geoTIFFTileSet, Bounds{
+		Min: Coord{X: 3000, Y: 500},
+		Max: Coord{X: 4500, Y: 1500},
+	}, WithWriterTileSize(32)))
+	data := buffer.Bytes()
+
+	geoTIFFTile, err := NewGeoTIFFTileFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	epsg, ok := geoTIFFTile.EPSG()
+	assert.True(t, ok)
+	assert.Equal(t, 3035, epsg)
+	width, length := geoTIFFTile.Size()
+	assert.Equal(t, 150, width)
+	assert.Equal(t, 100, length)
+
+	for y := range length {
+		for x := range width {
+			gx, gy := 200+x, 50+y
+			expected := math.NaN()
+			if gx < g.imageWidth && !g.isNoData(gx, gy) {
+				expected = g.sample(gx, gy)
+			}
+			actual, err := geoTIFFTile.Sample(t.Context(), Coord{X: 3000 + 10*x + 5, Y: 1500 - 10*y - 5})
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual, "%d, %d", x, y)
+		}
+	}
+}
+
+func TestWriteGeoTIFF_Errors(t *testing.T) {
+	raster := &testFuncRaster{
+		scaleX: 10,
+		scaleY: 10,
+		sample: func(Coord) float64 {
+			return 0
+		},
+	}
+	bounds := Bounds{Max: Coord{X: 100, Y: 100}}
+	for _, tc := range []struct {
+		name        string
+		raster      Raster
+		bounds      Bounds
+		options     []GeoTIFFWriterOption
+		expectedErr error
+	}{
+		{
+			name:        "unsupported_compression",
+			raster:      raster,
+			bounds:      bounds,
+			options:     []GeoTIFFWriterOption{WithWriterCompression(CompressionLERC)},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name:        "unsupported_srid",
+			raster:      raster,
+			bounds:      bounds,
+			options:     []GeoTIFFWriterOption{WithWriterSRID(100000)},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name:        "unsupported_model_type",
+			raster:      raster,
+			bounds:      bounds,
+			options:     []GeoTIFFWriterOption{WithWriterModelType(ModelTypeGeocentric)},
+			expectedErr: errors.ErrUnsupported,
+		},
+		{
+			name:    "tile_size",
+			raster:  raster,
+			bounds:  bounds,
+			options: []GeoTIFFWriterOption{WithWriterTileSize(100)},
+		},
+		{
+			name:   "empty_bounds",
+			raster: raster,
+			bounds: Bounds{Min: Coord{X: 100, Y: 0}, Max: Coord{X: 100, Y: 100}},
+		},
+		{
+			name:   "scale",
+			raster: &testFuncRaster{},
+			bounds: bounds,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			err := WriteGeoTIFF(t.Context(), &buffer, tc.raster, tc.bounds, tc.options...)
+			assert.Error(t, err)
+			if tc.expectedErr != nil {
+				assert.IsError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package elevation
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"slices"
+)
+
+// NewCopernicusGLO30 returns a new GeoTIFFTileSet that reads Copernicus DEM
+// GLO-30 tiles, named like Copernicus_DSM_COG_10_N46_00_E007_00_DEM.tif, from
+// fsys.
+func NewCopernicusGLO30(fsys fs.FS, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return newOneDegreeTileSet(fsys, copernicusTileFilenameFunc(10), options...)
+}
+
+// NewCopernicusGLO90 returns a new GeoTIFFTileSet that reads Copernicus DEM
+// GLO-90 tiles, named like Copernicus_DSM_COG_30_N46_00_E007_00_DEM.tif, from
+// fsys.
+func NewCopernicusGLO90(fsys fs.FS, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return newOneDegreeTileSet(fsys, copernicusTileFilenameFunc(30), options...)
+}
+
+// NewSRTM returns a new GeoTIFFTileSet that reads SRTM tiles, named like
+// N46E007.tif, from fsys.
+func NewSRTM(fsys fs.FS, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return newOneDegreeTileSet(fsys, func(tileCoord TileCoord) string {
+		return srtmTileName(tileCoord) + ".tif"
+	}, options...)
+}
+
+// NewASTERGDEM returns a new GeoTIFFTileSet that reads ASTER GDEM v3 tiles,
+// named like ASTGTMV003_N46E007_dem.tif, from fsys.
+func NewASTERGDEM(fsys fs.FS, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return newOneDegreeTileSet(fsys, func(tileCoord TileCoord) string {
+		return "ASTGTMV003_" + srtmTileName(tileCoord) + "_dem.tif"
+	}, options...)
+}
+
+// NewALOSAW3D30 returns a new GeoTIFFTileSet that reads ALOS World 3D 30m
+// tiles, named like ALPSMLC30_N046E007_DSM.tif, from fsys.
+func NewALOSAW3D30(fsys fs.FS, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return newOneDegreeTileSet(fsys, func(tileCoord TileCoord) string {
+		latitudeHemisphere, latitude := hemisphere(tileCoord.R, 'N', 'S')
+		longitudeHemisphere, longitude := hemisphere(tileCoord.C, 'E', 'W')
+		return fmt.Sprintf("ALPSMLC30_%c%03d%c%03d_DSM.tif", latitudeHemisphere, latitude, longitudeHemisphere, longitude)
+	}, options...)
+}
+
+// newOneDegreeTileSet returns a new GeoTIFFTileSet of WGS84 tiles that each
+// cover one degree of longitude and latitude. Coordinates are longitudes and
+// latitudes in degrees. A tile's column and row are the longitude and latitude
+// of its south west corner. Degrees cannot be represented as an integer scale,
+// so the tile set has no scale and functions that require one, like
+// [InterpolateBilinear] and [WriteGeoTIFF], return [ErrNoScale].
+func newOneDegreeTileSet(fsys fs.FS, tileFilenameFunc TileFilenameFunc, options ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error) {
+	return NewGeoTIFFTileSet(slices.Concat(
+		[]GeoTIFFTileSetOption{
+			WithFS(fsys),
+			WithSRID(4326),
+			WithTileCoordFunc(func(coord Coord) (TileCoord, bool) {
+				return oneDegreeTileCoord(float64(coord.X), float64(coord.Y))
+			}),
+			WithFloat64TileCoordFunc(func(coord Float64Coord) (TileCoord, bool) {
+				return oneDegreeTileCoord(coord.X, coord.Y)
+			}),
+			WithTileFilenameFunc(tileFilenameFunc),
+		},
+		options,
+	)...)
+}
+
+// oneDegreeTileCoord returns the coordinate of the one degree tile containing
+// longitude and latitude.
+func oneDegreeTileCoord(longitude, latitude float64) (TileCoord, bool) {
+	if !(-180 <= longitude && longitude < 180 && -90 <= latitude && latitude < 90) {
+		return TileCoord{}, false
+	}
+	return TileCoord{
+		C: int(math.Floor(longitude)),
+		R: int(math.Floor(latitude)),
+	}, true
+}
+
+// copernicusTileFilenameFunc returns a TileFilenameFunc for Copernicus DEM
+// tiles with a resolution of arcSeconds.
+func copernicusTileFilenameFunc(arcSeconds int) TileFilenameFunc {
+	return func(tileCoord TileCoord) string {
+		latitudeHemisphere, latitude := hemisphere(tileCoord.R, 'N', 'S')
+		longitudeHemisphere, longitude := hemisphere(tileCoord.C, 'E', 'W')
+		return fmt.Sprintf("Copernicus_DSM_COG_%d_%c%02d_00_%c%03d_00_DEM.tif", arcSeconds, latitudeHemisphere, latitude, longitudeHemisphere, longitude)
+	}
+}
+
+// srtmTileName returns the SRTM name of the tile at tileCoord, for example
+// N46E007.
+func srtmTileName(tileCoord TileCoord) string {
+	latitudeHemisphere, latitude := hemisphere(tileCoord.R, 'N', 'S')
+	longitudeHemisphere, longitude := hemisphere(tileCoord.C, 'E', 'W')
+	return fmt.Sprintf("%c%02d%c%03d", latitudeHemisphere, latitude, longitudeHemisphere, longitude)
+}
+
+// hemisphere returns positive and value if value is non-negative, or negative
+// and the absolute value of value otherwise.
+func hemisphere(value int, positive, negative byte) (byte, int) {
+	if value < 0 {
+		return negative, -value
+	}
+	return positive, value
+}
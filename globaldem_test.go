@@ -0,0 +1,130 @@
+package elevation
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestOneDegreeTileSets(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		newTileSetFunc    func(fs.FS, ...GeoTIFFTileSetOption) (*GeoTIFFTileSet, error)
+		expectedFilenames []string
+	}{
+		{
+			name:           "copernicus_glo30",
+			newTileSetFunc: NewCopernicusGLO30,
+			expectedFilenames: []string{
+				"Copernicus_DSM_COG_10_N46_00_E007_00_DEM.tif",
+				"Copernicus_DSM_COG_10_S01_00_W001_00_DEM.tif",
+				"Copernicus_DSM_COG_10_N00_00_W180_00_DEM.tif",
+			},
+		},
+		{
+			name:           "copernicus_glo90",
+			newTileSetFunc: NewCopernicusGLO90,
+			expectedFilenames: []string{
+				"Copernicus_DSM_COG_30_N46_00_E007_00_DEM.tif",
+				"Copernicus_DSM_COG_30_S01_00_W001_00_DEM.tif",
+				"Copernicus_DSM_COG_30_N00_00_W180_00_DEM.tif",
+			},
+		},
+		{
+			name:           "srtm",
+			newTileSetFunc: NewSRTM,
+			expectedFilenames: []string{
+				"N46E007.tif",
+				"S01W001.tif",
+				"N00W180.tif",
+			},
+		},
+		{
+			name:           "aster_gdem",
+			newTileSetFunc: NewASTERGDEM,
+			expectedFilenames: []string{
+				"ASTGTMV003_N46E007_dem.tif",
+				"ASTGTMV003_S01W001_dem.tif",
+				"ASTGTMV003_N00W180_dem.tif",
+			},
+		},
+		{
+			name:           "alos_aw3d30",
+			newTileSetFunc: NewALOSAW3D30,
+			expectedFilenames: []string{
+				"ALPSMLC30_N046E007_DSM.tif",
+				"ALPSMLC30_S001W001_DSM.tif",
+				"ALPSMLC30_N000W180_DSM.tif",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a tile at 7E 46N with 100 pixels per degree.
+			g := newTestGeoTIFF()
+			g.imageWidth = 100
+			g.imageLength = 100
+			g.pixelScale = []float64{0.01, 0.01, 0}
+			g.tiepoint = []float64{0, 0, 0, 7, 47, 0}
+			g.isNoData = func(x, y int) bool {
+				return false
+			}
+			g.geoKeys = []uint16{
+				1, 1, 0, 2,
+				1024, 0, 1, ModelTypeGeographic,
+				2048, 0, 1, 4326,
+			}
+			dir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, tc.expectedFilenames[0]), g.bytes(), 0o666))
+
+			tileSet, err := tc.newTileSetFunc(os.DirFS(dir))
+			assert.NoError(t, err)
+			assert.Equal(t, 4326, tileSet.SRID())
+
+			for i, coord := range []Float64Coord{
+				{X: 7.5, Y: 46.5},
+				{X: -0.5, Y: -0.5},
+				{X: -180, Y: 0},
+			} {
+				tileCoord, ok := tileSet.float64TileCoordFunc(coord)
+				assert.True(t, ok)
+				assert.Equal(t, tc.expectedFilenames[i], tileSet.tileFilenameFunc(tileCoord))
+			}
+			for _, coord := range []Float64Coord{
+				{X: 180, Y: 0},
+				{X: 0, Y: 90},
+				{X: 0, Y: -90.5},
+				{X: math.NaN(), Y: 0},
+			} {
+				_, ok := tileSet.float64TileCoordFunc(coord)
+				assert.False(t, ok)
+			}
+
+			actual, err := tileSet.SamplesFloat64(t.Context(), []Float64Coord{
+				{X: 7.005, Y: 46.995},
+				{X: 7.125, Y: 46.505},
+				{X: 8.5, Y: 46.5},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{g.sample(0, 0), g.sample(12, 49), math.NaN()}, actual)
+
+			// Integer coordinates are whole degrees.
+			tileCoord, ok := tileSet.tileCoordFunc(Coord{X: 7, Y: 46})
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedFilenames[0], tileSet.tileFilenameFunc(tileCoord))
+
+			// Degrees have no integer scale.
+			scaleX, scaleY := tileSet.Scale()
+			assert.Equal(t, 0, scaleX)
+			assert.Equal(t, 0, scaleY)
+			_, err = InterpolateBilinear(t.Context(), tileSet, [][]float64{{7.5, 46.5}})
+			assert.IsError(t, err, ErrNoScale)
+			err = WriteGeoTIFF(t.Context(), io.Discard, tileSet, Bounds{Min: Coord{X: 7, Y: 46}, Max: Coord{X: 8, Y: 47}})
+			assert.IsError(t, err, ErrNoScale)
+		})
+	}
+}
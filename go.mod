@@ -9,13 +9,14 @@ tool github.com/twpayne/go-elevation/cmd/elevation-example
 require (
 	github.com/alecthomas/assert/v2 v2.11.0
 	github.com/google/tiff v0.0.0-20161109161721-4b31f3041d9a
+	github.com/klauspost/compress v1.18.0
 	github.com/maypok86/otter/v2 v2.2.0
 	github.com/twpayne/go-proj/v11 v11.0.0
 	golang.org/x/image v0.26.0
+	golang.org/x/sys v0.34.0
 )
 
 require (
 	github.com/alecthomas/repr v0.4.0 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
-	golang.org/x/sys v0.34.0 // indirect
 )
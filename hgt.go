@@ -0,0 +1,441 @@
+package elevation
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/maypok86/otter/v2"
+)
+
+// hgtNoData is the sample value of voids in HGT files.
+const hgtNoData = -32768
+
+// ErrInvalidHGT is returned when a file is not a valid HGT file.
+var ErrInvalidHGT = errors.New("invalid HGT")
+
+// An HGTTile is an open SRTM HGT file. HGT files contain a square grid of
+// big-endian int16 samples, either 1201x1201 (3 arc-second) or 3601x3601 (1
+// arc-second), covering the one degree tile whose south west corner is encoded
+// in the filename, for example N46E007.hgt. Samples are at the corners of the
+// grid, so adjacent tiles share their edge rows and columns.
+//
+// Coordinates are longitudes and latitudes in degrees. Samples are taken from
+// the nearest grid point. The whole tile is read into memory when it is first
+// sampled.
+type HGTTile struct {
+	mutex     sync.Mutex
+	readerAt  io.ReaderAt
+	data      []byte
+	file      *sharedFile
+	size      int
+	longitude int
+	latitude  int
+}
+
+// An HGTTileSet is a set of HGT tiles.
+type HGTTileSet struct {
+	fsys         fs.FS
+	zip          bool
+	cacheSize    int
+	hgtTileCache *otter.Cache[TileCoord, *HGTTile]
+}
+
+// An HGTTileSetOption sets an option on an HGTTileSet.
+type HGTTileSetOption func(*HGTTileSet)
+
+// NewHGTTile returns a new HGTTile that reads filename from fsys. If filename
+// ends with .zip then the HGT file is read from the zip archive.
+func NewHGTTile(fsys fs.FS, filename string) (*HGTTile, error) {
+	tileCoord, err := parseHGTTileName(path.Base(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	readerAt, size, err := newFileReaderAt(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	closers := []io.Closer{file}
+
+	if strings.EqualFold(path.Ext(filename), ".zip") {
+		var hgtFile fs.File
+		readerAt, size, hgtFile, err = openHGTZip(readerAt, size)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		closers = append(closers, hgtFile)
+	}
+
+	t, err := NewHGTTileFromReaderAt(readerAt, size, tileCoord.C, tileCoord.R)
+	if err != nil {
+		_ = closeAll(closers)
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	t.file = newSharedFile(func() error {
+		return closeAll(closers)
+	})
+	return t, nil
+}
+
+// NewHGTTileFromReaderAt returns a new HGTTile that reads size bytes from r,
+// whose south west corner is at longitude and latitude. Closing the HGTTile
+// does not close r.
+func NewHGTTileFromReaderAt(r io.ReaderAt, size int64, longitude, latitude int) (*HGTTile, error) {
+	var samplesPerRow int
+	switch size {
+	case 2 * 1201 * 1201:
+		samplesPerRow = 1201
+	case 2 * 3601 * 3601:
+		samplesPerRow = 3601
+	default:
+		return nil, fmt.Errorf("%d bytes: %w", size, ErrInvalidHGT)
+	}
+	return &HGTTile{
+		readerAt: r,
+		file: newSharedFile(func() error {
+			return nil
+		}),
+		size:      samplesPerRow,
+		longitude: longitude,
+		latitude:  latitude,
+	}, nil
+}
+
+// Close closes t. If other goroutines are sampling t then its file is closed
+// when they finish, and subsequent samples return an error wrapping
+// [fs.ErrClosed].
+func (t *HGTTile) Close() error {
+	return t.file.Close()
+}
+
+// sharedTileFile returns t's file.
+func (t *HGTTile) sharedTileFile() *sharedFile {
+	return t.file
+}
+
+// Size returns t's width and length in samples.
+func (t *HGTTile) Size() (int, int) {
+	return t.size, t.size
+}
+
+// Scale returns t's scale. HGT tiles are in degrees, so, like
+// [HGTTileSet.Scale], it always returns zero.
+func (t *HGTTile) Scale() (int, int) {
+	return 0, 0
+}
+
+// Sample returns a single sample from t.
+func (t *HGTTile) Sample(ctx context.Context, coord Coord) (float64, error) {
+	return t.SampleFloat64(ctx, Float64Coord{X: float64(coord.X), Y: float64(coord.Y)})
+}
+
+// Samples returns multiple samples from t.
+func (t *HGTTile) Samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	if !t.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer t.file.release()
+	return t.samples(ctx, coords)
+}
+
+// SampleFloat64 returns a single sample from t at a floating point coordinate.
+func (t *HGTTile) SampleFloat64(ctx context.Context, coord Float64Coord) (float64, error) {
+	samples, err := t.SamplesFloat64(ctx, []Float64Coord{coord})
+	if err != nil {
+		return 0, err
+	}
+	return samples[0], nil
+}
+
+// SamplesFloat64 returns multiple samples from t at floating point
+// coordinates.
+func (t *HGTTile) SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	if !t.file.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer t.file.release()
+	return t.samplesFloat64(ctx, coords)
+}
+
+// samples is like Samples but the caller must hold a reference to t's file.
+func (t *HGTTile) samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	localCoords := make([]Coord, len(coords))
+	for i, coord := range coords {
+		localCoords[i] = t.localCoordFloat64(Float64Coord{X: float64(coord.X), Y: float64(coord.Y)})
+	}
+	return t.localSamples(ctx, localCoords)
+}
+
+// samplesFloat64 is like SamplesFloat64 but the caller must hold a reference
+// to t's file.
+func (t *HGTTile) samplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	localCoords := make([]Coord, len(coords))
+	for i, coord := range coords {
+		localCoords[i] = t.localCoordFloat64(coord)
+	}
+	return t.localSamples(ctx, localCoords)
+}
+
+// localSamples returns the samples at localCoords. The caller must hold a
+// reference to t's file.
+func (t *HGTTile) localSamples(ctx context.Context, localCoords []Coord) ([]float64, error) {
+	data, err := t.getData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]float64, len(localCoords))
+	for i, localCoord := range localCoords {
+		samples[i] = t.localSample(data, localCoord)
+	}
+	return samples, nil
+}
+
+// localCoordFloat64 returns the local coordinate of the grid point nearest to
+// coord. Coordinates outside t are mapped to an invalid local coordinate.
+func (t *HGTTile) localCoordFloat64(coord Float64Coord) Coord {
+	x := math.Floor((coord.X-float64(t.longitude))*float64(t.size-1) + 0.5)
+	y := math.Floor((float64(t.latitude+1)-coord.Y)*float64(t.size-1) + 0.5)
+	if !(0 <= x && x < float64(t.size) && 0 <= y && y < float64(t.size)) {
+		return Coord{X: -1, Y: -1}
+	}
+	return Coord{X: int(x), Y: int(y)}
+}
+
+// getData returns t's samples, reading them with a single read the first time
+// that it is called. Reading each sample separately would be very slow when t
+// is read over a network or from a zip archive.
+func (t *HGTTile) getData(ctx context.Context) ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.data != nil {
+		return t.data, nil
+	}
+	data := make([]byte, 2*t.size*t.size)
+	switch n, err := readAtContext(ctx, t.readerAt, data, 0); {
+	case n == len(data):
+	case err != nil:
+		return nil, err
+	default:
+		return nil, errShortRead
+	}
+	// t.readerAt is no longer needed, and might hold a copy of the data.
+	t.data, t.readerAt = data, nil
+	return data, nil
+}
+
+// localSample returns the sample at localCoord in data, or NaN if localCoord
+// is outside t or the sample is a void.
+func (t *HGTTile) localSample(data []byte, localCoord Coord) float64 {
+	if localCoord.X < 0 || localCoord.Y < 0 {
+		return math.NaN()
+	}
+	offset := 2 * (localCoord.Y*t.size + localCoord.X)
+	sample := int16(binary.BigEndian.Uint16(data[offset:]))
+	if sample == hgtNoData {
+		return math.NaN()
+	}
+	return float64(sample)
+}
+
+// NewHGTTileSet returns a new HGTTileSet that reads HGT tiles, named like
+// N46E007.hgt, from fsys. Coordinates are longitudes and latitudes in degrees.
+func NewHGTTileSet(fsys fs.FS, options ...HGTTileSetOption) (*HGTTileSet, error) {
+	s := &HGTTileSet{
+		fsys:      fsys,
+		cacheSize: 32,
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	var err error
+	s.hgtTileCache, err = otter.New(&otter.Options[TileCoord, *HGTTile]{
+		MaximumSize: s.cacheSize,
+		OnDeletion: func(e otter.DeletionEvent[TileCoord, *HGTTile]) {
+			_ = e.Value.Close()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WithHGTCacheSize sets the maximum number of open tiles. Each open tile that
+// has been sampled holds its samples in memory, which is about 2.9MB for 3
+// arc-second tiles and 26MB for 1 arc-second tiles.
+func WithHGTCacheSize(cacheSize int) HGTTileSetOption {
+	return func(s *HGTTileSet) {
+		s.cacheSize = cacheSize
+	}
+}
+
+// WithHGTZip sets whether tiles are read from zip archives, named like
+// N46E007.hgt.zip, instead of from HGT files.
+func WithHGTZip(zip bool) HGTTileSetOption {
+	return func(s *HGTTileSet) {
+		s.zip = zip
+	}
+}
+
+// Samples returns the samples at coords. Missing samples are represented by
+// NaNs.
+func (s *HGTTileSet) Samples(ctx context.Context, coords []Coord) ([]float64, error) {
+	return tileSetSamples(ctx, s.getTileCached, coords, func(coord Coord) (TileCoord, bool) {
+		return oneDegreeTileCoord(float64(coord.X), float64(coord.Y))
+	}, releaseAfter((*HGTTile).samples))
+}
+
+// SamplesFloat64 returns the samples at floating point coords. Missing samples
+// are represented by NaNs.
+func (s *HGTTileSet) SamplesFloat64(ctx context.Context, coords []Float64Coord) ([]float64, error) {
+	return tileSetSamples(ctx, s.getTileCached, coords, func(coord Float64Coord) (TileCoord, bool) {
+		return oneDegreeTileCoord(coord.X, coord.Y)
+	}, releaseAfter((*HGTTile).samplesFloat64))
+}
+
+// SRID returns s's SRID, which is always 4326.
+func (s *HGTTileSet) SRID() int {
+	return 4326
+}
+
+// Scale returns s's scale. HGT tiles are in degrees, which cannot be
+// represented as an integer scale, so it always returns zero and functions
+// that require a scale, like [InterpolateBilinear], return [ErrNoScale].
+func (s *HGTTileSet) Scale() (int, int) {
+	return 0, 0
+}
+
+// getTile returns the tile at the given tile coordinate.
+func (s *HGTTileSet) getTile(ctx context.Context, tileCoord TileCoord) (*HGTTile, error) {
+	filename := srtmTileName(tileCoord) + ".hgt"
+	if s.zip {
+		filename += ".zip"
+	}
+	hgtTile, err := NewHGTTile(s.fsys, filename)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, otter.ErrNotFound
+	case err != nil:
+		return nil, err
+	}
+	return hgtTile, nil
+}
+
+// getTileCached returns the tile at the give tile coordinate, using the cache
+// if possible. Like [GeoTIFFTileSet.getTileCached], it adds a reference to the
+// tile's file which the caller must release.
+func (s *HGTTileSet) getTileCached(ctx context.Context, tileCoord TileCoord) (*HGTTile, error) {
+	for {
+		hgtTile, err := s.hgtTileCache.Get(ctx, tileCoord, otter.LoaderFunc[TileCoord, *HGTTile](s.getTile))
+		if err != nil {
+			return nil, err
+		}
+		// If the tile was evicted and closed after it was returned by the
+		// cache then get it again.
+		if hgtTile.file.acquire() {
+			return hgtTile, nil
+		}
+	}
+}
+
+// openHGTZip opens the single HGT file in the zip archive of size bytes read
+// from r, and returns a reader for it, its size, and the open file.
+func openHGTZip(r io.ReaderAt, size int64) (io.ReaderAt, int64, fs.File, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var hgtFilename string
+	for _, zipFile := range zipReader.File {
+		if !strings.EqualFold(path.Ext(zipFile.Name), ".hgt") {
+			continue
+		}
+		if hgtFilename != "" {
+			return nil, 0, nil, fmt.Errorf("multiple HGT files: %w", ErrInvalidHGT)
+		}
+		hgtFilename = zipFile.Name
+	}
+	if hgtFilename == "" {
+		return nil, 0, nil, fmt.Errorf("no HGT file: %w", ErrInvalidHGT)
+	}
+	hgtFile, err := zipReader.Open(hgtFilename)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	hgtReaderAt, hgtSize, err := newFileReaderAt(hgtFile)
+	if err != nil {
+		_ = hgtFile.Close()
+		return nil, 0, nil, err
+	}
+	return hgtReaderAt, hgtSize, hgtFile, nil
+}
+
+// parseHGTTileName returns the tile coordinate of the south west corner encoded
+// in name, for example N46E007.hgt or N46E007.hgt.zip.
+func parseHGTTileName(name string) (TileCoord, error) {
+	tileName, ext, _ := strings.Cut(name, ".")
+	if len(tileName) != 7 || !strings.EqualFold(ext, "hgt") && !strings.EqualFold(ext, "hgt.zip") {
+		return TileCoord{}, fmt.Errorf("%s: invalid tile name: %w", name, ErrInvalidHGT)
+	}
+	latitude, ok1 := parseDigits(tileName[1:3])
+	longitude, ok2 := parseDigits(tileName[4:7])
+	if !ok1 || !ok2 {
+		return TileCoord{}, fmt.Errorf("%s: invalid tile name: %w", name, ErrInvalidHGT)
+	}
+	switch name[0] {
+	case 'N', 'n':
+	case 'S', 's':
+		latitude = -latitude
+	default:
+		return TileCoord{}, fmt.Errorf("%s: invalid tile name: %w", name, ErrInvalidHGT)
+	}
+	switch name[3] {
+	case 'E', 'e':
+	case 'W', 'w':
+		longitude = -longitude
+	default:
+		return TileCoord{}, fmt.Errorf("%s: invalid tile name: %w", name, ErrInvalidHGT)
+	}
+	if latitude < -90 || latitude > 89 || longitude < -180 || longitude > 179 {
+		return TileCoord{}, fmt.Errorf("%s: invalid tile name: %w", name, ErrInvalidHGT)
+	}
+	return TileCoord{C: longitude, R: latitude}, nil
+}
+
+// parseDigits returns the non-negative decimal integer s. Unlike
+// strconv.Atoi, it does not accept signs.
+func parseDigits(s string) (int, bool) {
+	value := 0
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		value = 10*value + int(c-'0')
+	}
+	return value, true
+}
+
+// closeAll closes closers in reverse order.
+func closeAll(closers []io.Closer) error {
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		errs = append(errs, closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,385 @@
+package elevation
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+var (
+	_ Raster        = &HGTTile{}
+	_ Raster        = &HGTTileSet{}
+	_ Float64Raster = &HGTTile{}
+	_ Float64Raster = &HGTTileSet{}
+)
+
+// testHGTSample returns the sample at x, y in test HGT files, which is a void
+// on the diagonal.
+func testHGTSample(x, y int) float64 {
+	if x == y {
+		return math.NaN()
+	}
+	return float64((7*x+13*y)%4000 - 100)
+}
+
+// newTestHGT returns a 3 arc-second test HGT file.
+func newTestHGT() []byte {
+	data := make([]byte, 0, 2*1201*1201)
+	for y := range 1201 {
+		for x := range 1201 {
+			sample := int16(hgtNoData)
+			if value := testHGTSample(x, y); !math.IsNaN(value) {
+				sample = int16(value)
+			}
+			data = binary.BigEndian.AppendUint16(data, uint16(sample))
+		}
+	}
+	return data
+}
+
+// A countingReaderAt is an io.ReaderAt that counts its reads.
+type countingReaderAt struct {
+	io.ReaderAt
+	reads atomic.Int64
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads.Add(1)
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+// newTestHGTZip returns a zip archive containing files.
+func newTestHGTZip(t *testing.T, method uint16, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   name,
+			Method: method,
+		})
+		assert.NoError(t, err)
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func TestHGTTile(t *testing.T) {
+	data := newTestHGT()
+
+	for _, tc := range []struct {
+		name     string
+		filename string
+		fsys     fs.FS
+	}{
+		{
+			name:     "hgt",
+			filename: "N46E007.hgt",
+			fsys: fstest.MapFS{
+				"N46E007.hgt": &fstest.MapFile{Data: data},
+			},
+		},
+		{
+			name:     "zip_store",
+			filename: "N46E007.hgt.zip",
+			fsys: fstest.MapFS{
+				"N46E007.hgt.zip": &fstest.MapFile{
+					Data: newTestHGTZip(t, zip.Store, map[string][]byte{"N46E007.hgt": data}),
+				},
+			},
+		},
+		{
+			name:     "zip_deflate",
+			filename: "srtm/N46E007.hgt.zip",
+			fsys: fstest.MapFS{
+				"srtm/N46E007.hgt.zip": &fstest.MapFile{
+					Data: newTestHGTZip(t, zip.Deflate, map[string][]byte{"N46E007.hgt": data}),
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hgtTile, err := NewHGTTile(tc.fsys, tc.filename)
+			assert.NoError(t, err)
+			defer func() {
+				assert.NoError(t, hgtTile.Close())
+			}()
+			width, length := hgtTile.Size()
+			assert.Equal(t, 1201, width)
+			assert.Equal(t, 1201, length)
+
+			actual, err := hgtTile.SamplesFloat64(t.Context(), []Float64Coord{
+				{X: 7, Y: 47},
+				{X: 8, Y: 46},
+				{X: 7, Y: 46},
+				{X: 7 + 100.0/1200, Y: 47 - 200.0/1200},
+				{X: 7 + 100.4/1200, Y: 47 - 199.6/1200},
+				{X: 7 + 500.0/1200, Y: 47 - 500.0/1200},
+				{X: 6.9, Y: 46.5},
+				{X: 7.5, Y: 47.1},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{
+				testHGTSample(0, 0),
+				testHGTSample(1200, 1200),
+				testHGTSample(0, 1200),
+				testHGTSample(100, 200),
+				testHGTSample(100, 200),
+				math.NaN(),
+				math.NaN(),
+				math.NaN(),
+			}, actual)
+
+			sample, err := hgtTile.Sample(t.Context(), Coord{X: 8, Y: 47})
+			assert.NoError(t, err)
+			assert.Equal(t, testHGTSample(1200, 0), sample)
+		})
+	}
+}
+
+func TestHGTTile_Reads(t *testing.T) {
+	data := newTestHGT()
+	r := &countingReaderAt{ReaderAt: bytes.NewReader(data)}
+	hgtTile, err := NewHGTTileFromReaderAt(r, int64(len(data)), 7, 46)
+	assert.NoError(t, err)
+	scaleX, scaleY := hgtTile.Scale()
+	assert.Equal(t, 0, scaleX)
+	assert.Equal(t, 0, scaleY)
+	assert.Equal(t, 0, r.reads.Load())
+
+	// The whole tile is read with a single read when it is first sampled.
+	for range 2 {
+		actual, err := hgtTile.SamplesFloat64(t.Context(), []Float64Coord{
+			{X: 7, Y: 47},
+			{X: 7 + 100.0/1200, Y: 47 - 200.0/1200},
+			{X: 8, Y: 46},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{
+			testHGTSample(0, 0),
+			testHGTSample(100, 200),
+			testHGTSample(1200, 1200),
+		}, actual)
+		assert.Equal(t, 1, r.reads.Load())
+	}
+}
+
+func TestHGTTile_Errors(t *testing.T) {
+	data := newTestHGT()
+	fsys := fstest.MapFS{
+		"N46E007.hgt":     &fstest.MapFile{Data: data[:len(data)-2]},
+		"N46E008.hgt.zip": &fstest.MapFile{Data: newTestHGTZip(t, zip.Store, map[string][]byte{"readme.txt": nil})},
+		"N46E009.hgt.zip": &fstest.MapFile{Data: newTestHGTZip(t, zip.Store, map[string][]byte{"a.hgt": data, "b.hgt": data})},
+		"X46E007.hgt":     &fstest.MapFile{Data: data},
+		"N46E1x0.hgt":     &fstest.MapFile{Data: data},
+		"N91E007.hgt":     &fstest.MapFile{Data: data},
+	}
+	for _, filename := range []string{
+		"N46E007.hgt",
+		"N46E008.hgt.zip",
+		"N46E009.hgt.zip",
+		"X46E007.hgt",
+		"N46E1x0.hgt",
+		"N91E007.hgt",
+		"hgt",
+	} {
+		t.Run(filename, func(t *testing.T) {
+			_, err := NewHGTTile(fsys, filename)
+			assert.IsError(t, err, ErrInvalidHGT)
+		})
+	}
+
+	_, err := NewHGTTile(fsys, "N00E000.hgt")
+	assert.IsError(t, err, fs.ErrNotExist)
+}
+
+func TestParseHGTTileName(t *testing.T) {
+	for name, expected := range map[string]TileCoord{
+		"N46E007.hgt":     {C: 7, R: 46},
+		"n46e007.hgt":     {C: 7, R: 46},
+		"S01W001.hgt.zip": {C: -1, R: -1},
+		"S90W180.hgt":     {C: -180, R: -90},
+		"N89E179.hgt":     {C: 179, R: 89},
+		"N00E000.HGT":     {C: 0, R: 0},
+	} {
+		actual, err := parseHGTTileName(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, actual, name)
+		assert.Equal(t, strings.ToUpper(name[:7]), srtmTileName(actual), name)
+	}
+
+	for _, name := range []string{
+		"",
+		"N46E007",
+		"N46E07.hgt",
+		"N046E007.hgt",
+		"N46E007x.hgt",
+		"N46E007.hgtx",
+		"N46E007.hgt.zip.bak",
+		"N46E007.tif",
+		"N+4E007.hgt",
+		"N46E-07.hgt",
+		"N90E000.hgt",
+		"S91E000.hgt",
+		"N00E180.hgt",
+		"N00W181.hgt",
+	} {
+		_, err := parseHGTTileName(name)
+		assert.IsError(t, err, ErrInvalidHGT, name)
+	}
+}
+
+func TestHGTTileSet_ConcurrentEviction(t *testing.T) {
+	// Create four tiles at 6E to 9E.
+	data := newTestHGT()
+	fsys := make(fstest.MapFS)
+	for i := range 4 {
+		fsys[srtmTileName(TileCoord{C: 6 + i, R: 46})+".hgt"] = &fstest.MapFile{Data: data}
+	}
+
+	// With a cache size of one, tiles are evicted and closed while other
+	// goroutines are sampling them.
+	hgtTileSet, err := NewHGTTileSet(slowFS{fsys: fsys}, WithHGTCacheSize(1))
+	assert.NoError(t, err)
+
+	coords := make([][]Float64Coord, 4)
+	var expected []float64
+	for y := 5; y < 1200; y += 10 {
+		for x := 5; x < 1200; x += 10 {
+			for i := range 4 {
+				coords[i] = append(coords[i], Float64Coord{X: float64(6+i) + float64(x)/1200, Y: 47 - float64(y)/1200})
+			}
+			expected = append(expected, testHGTSample(x, y))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				k := (i + j) % 4
+				actual, err := hgtTileSet.SamplesFloat64(t.Context(), coords[k])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !slices.EqualFunc(expected, actual, func(a, b float64) bool {
+					return a == b || math.IsNaN(a) && math.IsNaN(b)
+				}) {
+					t.Errorf("tile %d: unexpected samples", k)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// A slowFS is an fs.FS whose files are slow to read and cannot be read once
+// they are closed, so that reads from closed files are detected.
+type slowFS struct {
+	fsys fs.FS
+}
+
+func (fsys slowFS) Open(name string) (fs.File, error) {
+	file, err := fsys.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &slowFile{File: file}, nil
+}
+
+// A slowFile is a file opened from a slowFS.
+type slowFile struct {
+	fs.File
+	closed atomic.Bool
+}
+
+func (f *slowFile) Close() error {
+	f.closed.Store(true)
+	return f.File.Close()
+}
+
+func (f *slowFile) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(time.Millisecond)
+	if f.closed.Load() {
+		return 0, fs.ErrClosed
+	}
+	return f.File.(io.ReaderAt).ReadAt(p, off)
+}
+
+func TestHGTTileSet(t *testing.T) {
+	data := newTestHGT()
+
+	for _, tc := range []struct {
+		name    string
+		fsys    fs.FS
+		options []HGTTileSetOption
+	}{
+		{
+			name: "hgt",
+			fsys: fstest.MapFS{
+				"N46E007.hgt": &fstest.MapFile{Data: data},
+				"S01W001.hgt": &fstest.MapFile{Data: data},
+			},
+		},
+		{
+			name: "zip",
+			fsys: fstest.MapFS{
+				"N46E007.hgt.zip": &fstest.MapFile{
+					Data: newTestHGTZip(t, zip.Deflate, map[string][]byte{"N46E007.hgt": data}),
+				},
+				"S01W001.hgt.zip": &fstest.MapFile{
+					Data: newTestHGTZip(t, zip.Deflate, map[string][]byte{"S01W001.hgt": data}),
+				},
+			},
+			options: []HGTTileSetOption{
+				WithHGTZip(true),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hgtTileSet, err := NewHGTTileSet(tc.fsys, tc.options...)
+			assert.NoError(t, err)
+			assert.Equal(t, 4326, hgtTileSet.SRID())
+
+			actual, err := hgtTileSet.SamplesFloat64(t.Context(), []Float64Coord{
+				{X: 7 + 100.0/1200, Y: 47 - 200.0/1200},
+				{X: -1 + 300.0/1200, Y: -400.0 / 1200},
+				{X: 7 + 600.0/1200, Y: 47 - 600.0/1200},
+				{X: 8.5, Y: 46.5},
+				{X: 180, Y: 0},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{
+				testHGTSample(100, 200),
+				testHGTSample(300, 400),
+				math.NaN(),
+				math.NaN(),
+				math.NaN(),
+			}, actual)
+
+			// Integer coordinates are whole degrees.
+			actual, err = hgtTileSet.Samples(t.Context(), []Coord{{X: 7, Y: 46}, {X: -1, Y: -1}})
+			assert.NoError(t, err)
+			assert.Equal(t, []float64{testHGTSample(0, 1200), testHGTSample(0, 1200)}, actual)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package elevation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errHTTPRangeNotSupported = errors.New("HTTP range requests not supported")
+
+// An HTTPFS is an fs.FS that reads files from an HTTP server using range
+// requests. Its files implement io.ReaderAt, so GeoTIFFTiles opened from an
+// HTTPFS only fetch the header and the tiles that they sample. Reads made while
+// sampling are canceled when the sample's context is canceled. The server must
+// support range requests.
+type HTTPFS struct {
+	baseURL    string
+	client     *http.Client
+	headerSize int
+}
+
+// An HTTPFSOption sets an option on an HTTPFS.
+type HTTPFSOption func(*HTTPFS)
+
+// An httpFile is a file read from an HTTPFS.
+type httpFile struct {
+	client *http.Client
+	name   string
+	url    string
+	size   int64
+	header []byte
+	offset int64
+}
+
+// An httpFileInfo describes an httpFile.
+type httpFileInfo struct {
+	name string
+	size int64
+}
+
+// NewHTTPFS returns a new HTTPFS that reads files relative to baseURL.
+func NewHTTPFS(baseURL string, options ...HTTPFSOption) *HTTPFS {
+	fsys := &HTTPFS{
+		baseURL:    baseURL,
+		client:     http.DefaultClient,
+		headerSize: 16 << 10, // 16KB.
+	}
+	for _, option := range options {
+		option(fsys)
+	}
+	return fsys
+}
+
+// WithHTTPClient sets the http.Client used to make requests.
+func WithHTTPClient(client *http.Client) HTTPFSOption {
+	return func(fsys *HTTPFS) {
+		fsys.client = client
+	}
+}
+
+// WithHTTPHeaderSize sets the number of bytes fetched from the start of each
+// file when it is opened. The GeoTIFF header and IFDs are normally at the
+// start of the file, so fetching them together avoids many small requests.
+func WithHTTPHeaderSize(headerSize int) HTTPFSOption {
+	return func(fsys *HTTPFS) {
+		fsys.headerSize = headerSize
+	}
+}
+
+// Open implements fs.FS.
+func (fsys *HTTPFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fileURL, err := url.JoinPath(fsys.baseURL, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f := &httpFile{
+		client: fsys.client,
+		name:   name,
+		url:    fileURL,
+	}
+	if err := f.readHeader(fsys.headerSize); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+// Close implements fs.File.
+func (f *httpFile) Close() error {
+	return nil
+}
+
+// Read implements io.Reader.
+func (f *httpFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.ReadAtContext(context.Background(), p, off)
+}
+
+// ReadAtContext is like ReadAt but makes any request with ctx.
+func (f *httpFile) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), f.size)
+	if end <= int64(len(f.header)) {
+		n := copy(p, f.header[off:end])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+
+	resp, err := f.get(ctx, off, end)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("%s: %s", f.url, resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p[:end-off])
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat implements fs.File.
+func (f *httpFile) Stat() (fs.FileInfo, error) {
+	return httpFileInfo{
+		name: f.name,
+		size: f.size,
+	}, nil
+}
+
+// get requests the bytes from start to end.
+func (f *httpFile) get(ctx context.Context, start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end-1, 10))
+	return f.client.Do(req)
+}
+
+// readHeader reads the first headerSize bytes of f and f's size. fs.FS has no
+// context, so the request cannot be canceled.
+func (f *httpFile) readHeader(headerSize int) error {
+	resp, err := f.get(context.Background(), 0, int64(max(headerSize, 1)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range. Reading the body would download the
+		// whole file, and every later read would do the same.
+		return fmt.Errorf("%s: %w", f.url, errHTTPRangeNotSupported)
+	case http.StatusPartialContent:
+		f.size, err = parseContentRangeSize(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		f.header, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file is empty.
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	default:
+		return fmt.Errorf("%s: %s", f.url, resp.Status)
+	}
+	return nil
+}
+
+func (i httpFileInfo) IsDir() bool        { return false }
+func (i httpFileInfo) ModTime() time.Time { return time.Time{} }
+func (i httpFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i httpFileInfo) Name() string       { return i.name[strings.LastIndexByte(i.name, '/')+1:] }
+func (i httpFileInfo) Size() int64        { return i.size }
+func (i httpFileInfo) Sys() any           { return nil }
+
+// parseContentRangeSize returns the complete length from a Content-Range
+// header value, for example 1234 from "bytes 0-99/1234".
+func parseContentRangeSize(contentRange string) (int64, error) {
+	_, size, ok := strings.Cut(contentRange, "/")
+	if !ok || !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, fmt.Errorf("content range %q: invalid", contentRange)
+	}
+	if size == "*" {
+		return 0, fmt.Errorf("content range %q: unknown size", contentRange)
+	}
+	return strconv.ParseInt(size, 10, 64)
+}
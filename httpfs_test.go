@@ -0,0 +1,206 @@
+package elevation
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestHTTPFS(t *testing.T) {
+	// testdata/geotiff/cog.tif is a 640x512 pixel COG in EPSG:3035, written by
+	// WriteCOG, with DEFLATE-compressed 128x128 pixel tiles and overviews. Its
+	// top left corner is at 4000000, 3012800, its pixel scale is 25, and its
+	// samples are 100+(7*x+3*y)%500, where x and y count pixels from the
+	// bottom left corner.
+	data, err := os.ReadFile("testdata/geotiff/cog.tif")
+	assert.NoError(t, err)
+
+	var requests atomic.Int64
+	fileServer := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fileServer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	fsys := NewHTTPFS(server.URL, WithHTTPClient(server.Client()), WithHTTPHeaderSize(1024))
+
+	// Sample the center of every pixel, and some coordinates outside the
+	// raster.
+	var coords []Coord
+	for y := 3012800 - 12; y > 3000000; y -= 25 {
+		for x := 4000000 + 12; x < 4016000; x += 25 {
+			coords = append(coords, Coord{X: x, Y: y})
+		}
+	}
+	coords = append(coords, Coord{X: 3999990, Y: 3006400}, Coord{X: 4008000, Y: 3012810})
+	dirGeoTIFFTile, err := NewGeoTIFFTile(os.DirFS("testdata"), "geotiff/cog.tif")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, dirGeoTIFFTile.Close())
+	}()
+	expected, err := dirGeoTIFFTile.Samples(t.Context(), coords)
+	assert.NoError(t, err)
+	assert.Equal(t, 100+(3*511)%500, expected[0])
+	assert.Equal(t, 100+(7*639)%500, expected[len(expected)-3])
+	assert.True(t, math.IsNaN(expected[len(expected)-2]))
+	assert.True(t, math.IsNaN(expected[len(expected)-1]))
+
+	t.Run("read", func(t *testing.T) {
+		file, err := fsys.Open("geotiff/cog.tif")
+		assert.NoError(t, err)
+		fileInfo, err := file.Stat()
+		assert.NoError(t, err)
+		assert.Equal(t, "cog.tif", fileInfo.Name())
+		assert.Equal(t, int64(len(data)), fileInfo.Size())
+		actual, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, data, actual)
+		assert.NoError(t, file.Close())
+	})
+
+	t.Run("read_at", func(t *testing.T) {
+		file, err := fsys.Open("geotiff/cog.tif")
+		assert.NoError(t, err)
+		readerAt, ok := file.(io.ReaderAt)
+		assert.True(t, ok)
+		for _, tc := range []struct {
+			off int64
+			n   int
+		}{
+			{off: 0, n: 16},
+			{off: 1000, n: 100},
+			{off: 2000, n: 10000},
+			{off: int64(len(data)) - 10, n: 10},
+		} {
+			buf := make([]byte, tc.n)
+			n, err := readerAt.ReadAt(buf, tc.off)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.n, n)
+			assert.Equal(t, data[tc.off:tc.off+int64(tc.n)], buf)
+		}
+
+		buf := make([]byte, 20)
+		n, err := readerAt.ReadAt(buf, int64(len(data))-10)
+		assert.IsError(t, err, io.EOF)
+		assert.Equal(t, 10, n)
+
+		_, err = readerAt.ReadAt(buf, int64(len(data)))
+		assert.IsError(t, err, io.EOF)
+	})
+
+	t.Run("not_exist", func(t *testing.T) {
+		_, err := fsys.Open("geotiff/missing.tif")
+		assert.IsError(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := fsys.Open("../cog.tif")
+		assert.IsError(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("geotifftile", func(t *testing.T) {
+		geoTIFFTile, err := NewGeoTIFFTile(fsys, "geotiff/cog.tif")
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, geoTIFFTile.Close())
+		}()
+
+		// Sampling every pixel should read all the tiles of the full
+		// resolution image, which are adjacent in the file, with a single
+		// request.
+		requests.Store(0)
+		actual, err := geoTIFFTile.Samples(t.Context(), coords)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, 1, requests.Load())
+
+		// Sampling again should use the cache.
+		actual, err = geoTIFFTile.Samples(t.Context(), coords)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, 1, requests.Load())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		geoTIFFTile, err := NewGeoTIFFTile(fsys, "geotiff/cog.tif")
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, geoTIFFTile.Close())
+		}()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		requests.Store(0)
+		_, err = geoTIFFTile.Samples(ctx, coords[:1])
+		assert.IsError(t, err, context.Canceled)
+		assert.Equal(t, 0, requests.Load())
+	})
+
+	t.Run("geotifftileset", func(t *testing.T) {
+		geoTIFFTileSet, err := NewGeoTIFFTileSet(
+			WithFS(fsys),
+			WithTileCoordFunc(func(coord Coord) (TileCoord, bool) {
+				return TileCoord{C: (coord.X - 4000000) / 100000}, true
+			}),
+			WithTileFilenameFunc(func(tileCoord TileCoord) string {
+				if tileCoord.C == 0 {
+					return "geotiff/cog.tif"
+				}
+				return "geotiff/missing.tif"
+			}),
+		)
+		assert.NoError(t, err)
+		actual, err := geoTIFFTileSet.Samples(t.Context(), []Coord{
+			coords[0],
+			{X: coords[0].X + 100000, Y: coords[0].Y},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{expected[0], math.NaN()}, actual)
+	})
+}
+
+func TestHTTPFS_RangeNotSupported(t *testing.T) {
+	fileServer := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Range")
+		fileServer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	fsys := NewHTTPFS(server.URL, WithHTTPClient(server.Client()))
+	_, err := fsys.Open("geotiff/cog.tif")
+	assert.IsError(t, err, errHTTPRangeNotSupported)
+}
+
+func TestParseContentRangeSize(t *testing.T) {
+	for _, tc := range []struct {
+		contentRange string
+		expected     int64
+		expectedErr  bool
+	}{
+		{contentRange: "bytes 0-99/1234", expected: 1234},
+		{contentRange: "bytes 0-99/*", expectedErr: true},
+		{contentRange: "bytes 0-99", expectedErr: true},
+		{contentRange: "items 0-99/1234", expectedErr: true},
+		{contentRange: "", expectedErr: true},
+	} {
+		t.Run(tc.contentRange, func(t *testing.T) {
+			actual, err := parseContentRangeSize(tc.contentRange)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package elevation
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 func InterpolateBilinear(ctx context.Context, raster Raster, coords [][]float64) ([]float64, error) {
 	scaleX, scaleY := raster.Scale()
+	if scaleX <= 0 || scaleY <= 0 {
+		return nil, fmt.Errorf("scale %d, %d: %w", scaleX, scaleY, ErrNoScale)
+	}
 	rasterCoords := make([]Coord, 4*len(coords))
 	for i, coord := range coords {
 		x0 := scaleX * (int(coord[0]) / scaleX)
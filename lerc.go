@@ -0,0 +1,946 @@
+package elevation
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// LERC (Limited Error Raster Compression) decoding.
+//
+// Only the subset of Lerc2 that is used for single band rasters is supported:
+// Lerc2 versions 2 to 6 with a depth of one, optionally compressed with
+// DEFLATE or ZSTD as written by GDAL. This includes the Huffman encoding that
+// LERC uses for lossless 8-bit data and the lossless floating point encoding
+// that Lerc2 version 6 uses for floating point data with a maximum error of
+// zero.
+
+const (
+	lercMinVersion      = 2
+	lercMaxVersion      = 6
+	lercChecksumVersion = 3
+)
+
+var (
+	errInvalidLERC  = errors.New("invalid LERC blob")
+	errLERCChecksum = errors.New("LERC checksum mismatch")
+	lercFileKey     = []byte("Lerc2 ")
+	zstdMagicNumber = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Lerc2 data types.
+const (
+	lercDataTypeChar = iota
+	lercDataTypeByte
+	lercDataTypeShort
+	lercDataTypeUShort
+	lercDataTypeInt
+	lercDataTypeUInt
+	lercDataTypeFloat
+	lercDataTypeDouble
+)
+
+// Lerc2 image encode modes.
+const (
+	lercImageEncodeTiling = iota
+	lercImageEncodeDeltaHuffman
+	lercImageEncodeHuffman
+	lercImageEncodeDeltaDeltaHuffman
+)
+
+// lercHuffmanMinVersion is the minimum version of Huffman code tables.
+const lercHuffmanMinVersion = 2
+
+// lercDataTypeSizes contains the size in bytes of each Lerc2 data type.
+var lercDataTypeSizes = []int{1, 1, 2, 2, 4, 4, 4, 8}
+
+// A lercHeader is a Lerc2 header.
+type lercHeader struct {
+	version        int
+	checksum       uint32
+	nRows          int
+	nCols          int
+	nDepth         int
+	numValidPixel  int
+	microBlockSize int
+	blobSize       int
+	dataType       int
+	nBlobsMore     int
+	maxZError      float64
+	zMin           float64
+	zMax           float64
+}
+
+// A lercDecoder decodes a single Lerc2 blob.
+type lercDecoder struct {
+	src    []byte
+	header lercHeader
+	mask   []byte
+	values []float64
+}
+
+// decompressLERC decompresses LERC-compressed data from src into dst. The
+// LERC blob may itself be compressed with DEFLATE or ZSTD. Samples are written
+// in little-endian byte order. Invalid floating point samples are written as
+// NaNs and invalid integer samples as zeros.
+func decompressLERC(dst, src []byte) error {
+	switch {
+	case bytes.HasPrefix(src, zstdMagicNumber):
+		zstdDecoder, err := getZSTDDecoder()
+		if err != nil {
+			return err
+		}
+		src, err = zstdDecoder.DecodeAll(src, nil)
+		if err != nil {
+			return err
+		}
+	case isZlibHeader(src):
+		r, err := zlib.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return err
+		}
+		src, err = io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := r.Close(); err != nil {
+			return err
+		}
+	}
+
+	d := &lercDecoder{
+		src: src,
+	}
+	if err := d.decode(); err != nil {
+		return err
+	}
+	return d.write(dst)
+}
+
+// decode decodes d's blob.
+func (d *lercDecoder) decode() error {
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+	if err := d.readMask(); err != nil {
+		return err
+	}
+
+	h := &d.header
+	d.values = make([]float64, h.nRows*h.nCols)
+	if h.numValidPixel == 0 {
+		return nil
+	}
+	if h.zMin == h.zMax {
+		d.fill(h.zMin)
+		return nil
+	}
+
+	if h.version >= 4 {
+		zMin, ok := d.readValue(h.dataType)
+		if !ok {
+			return errInvalidLERC
+		}
+		zMax, ok := d.readValue(h.dataType)
+		if !ok {
+			return errInvalidLERC
+		}
+		if zMin == zMax {
+			d.fill(zMin)
+			return nil
+		}
+	}
+
+	readDataOneSweep, ok := d.readByte()
+	if !ok {
+		return errInvalidLERC
+	}
+	if readDataOneSweep != 0 {
+		for k := range d.values {
+			if d.isValid(k) {
+				if d.values[k], ok = d.readValue(h.dataType); !ok {
+					return errInvalidLERC
+				}
+			}
+		}
+		return nil
+	}
+
+	tryHuffmanInt := (h.dataType == lercDataTypeChar || h.dataType == lercDataTypeByte) && h.maxZError == 0.5
+	tryHuffmanFlt := h.version >= 6 && (h.dataType == lercDataTypeFloat || h.dataType == lercDataTypeDouble) && h.maxZError == 0
+	if tryHuffmanInt || tryHuffmanFlt {
+		imageEncodeMode, ok := d.readByte()
+		if !ok {
+			return errInvalidLERC
+		}
+		switch {
+		case imageEncodeMode == lercImageEncodeTiling:
+		case tryHuffmanInt && imageEncodeMode == lercImageEncodeDeltaHuffman:
+			return d.readHuffman(true)
+		case tryHuffmanInt && imageEncodeMode == lercImageEncodeHuffman && h.version >= 4:
+			return d.readHuffman(false)
+		case tryHuffmanFlt && imageEncodeMode == lercImageEncodeDeltaDeltaHuffman:
+			return d.readFPL()
+		default:
+			return errInvalidLERC
+		}
+	}
+
+	return d.readTiles()
+}
+
+// readHeader reads d's header.
+func (d *lercDecoder) readHeader() error {
+	if !bytes.HasPrefix(d.src, lercFileKey) {
+		return fmt.Errorf("LERC: %w", errors.ErrUnsupported)
+	}
+	blob := d.src
+	d.src = d.src[len(lercFileKey):]
+
+	h := &d.header
+	version, ok := d.readInt32()
+	if !ok {
+		return errInvalidLERC
+	}
+	h.version = int(version)
+	if h.version < lercMinVersion || lercMaxVersion < h.version {
+		return fmt.Errorf("LERC version %d: %w", h.version, errors.ErrUnsupported)
+	}
+	if h.version >= lercChecksumVersion {
+		checksum, ok := d.readInt32()
+		if !ok {
+			return errInvalidLERC
+		}
+		h.checksum = uint32(checksum)
+	}
+
+	nInts := 6
+	if h.version >= 4 {
+		nInts = 7
+	}
+	if h.version >= 6 {
+		nInts = 8
+	}
+	ints := make([]int, nInts)
+	for i := range ints {
+		value, ok := d.readInt32()
+		if !ok {
+			return errInvalidLERC
+		}
+		ints[i] = int(value)
+	}
+	h.nRows, ints = ints[0], ints[1:]
+	h.nCols, ints = ints[0], ints[1:]
+	h.nDepth = 1
+	if h.version >= 4 {
+		h.nDepth, ints = ints[0], ints[1:]
+	}
+	h.numValidPixel, ints = ints[0], ints[1:]
+	h.microBlockSize, ints = ints[0], ints[1:]
+	h.blobSize, ints = ints[0], ints[1:]
+	h.dataType, ints = ints[0], ints[1:]
+	if h.version >= 6 {
+		h.nBlobsMore = ints[0]
+		// Skip the no data and reserved flags.
+		if len(d.src) < 4 {
+			return errInvalidLERC
+		}
+		d.src = d.src[4:]
+	}
+
+	nDoubles := 3
+	if h.version >= 6 {
+		nDoubles = 5
+	}
+	doubles := make([]float64, nDoubles)
+	for i := range doubles {
+		if doubles[i], ok = d.readValue(lercDataTypeDouble); !ok {
+			return errInvalidLERC
+		}
+	}
+	h.maxZError, h.zMin, h.zMax = doubles[0], doubles[1], doubles[2]
+
+	switch {
+	case h.nRows <= 0 || h.nCols <= 0 || h.nRows > math.MaxInt32/h.nCols:
+		return errInvalidLERC
+	case h.numValidPixel < 0 || h.numValidPixel > h.nRows*h.nCols:
+		return errInvalidLERC
+	case h.microBlockSize <= 0:
+		return errInvalidLERC
+	case h.dataType < lercDataTypeChar || lercDataTypeDouble < h.dataType:
+		return errInvalidLERC
+	case h.blobSize < len(blob)-len(d.src) || h.blobSize > len(blob):
+		return errInvalidLERC
+	case h.nDepth != 1:
+		return fmt.Errorf("LERC depth %d: %w", h.nDepth, errors.ErrUnsupported)
+	case h.nBlobsMore != 0:
+		return fmt.Errorf("LERC with multiple blobs: %w", errors.ErrUnsupported)
+	}
+
+	if h.version >= lercChecksumVersion {
+		checksumStart := len(lercFileKey) + 4 + 4
+		if lercChecksum(blob[checksumStart:h.blobSize]) != h.checksum {
+			return errLERCChecksum
+		}
+	}
+
+	// Ignore any data after the blob.
+	d.src = d.src[:h.blobSize-(len(blob)-len(d.src))]
+	return nil
+}
+
+// readMask reads d's mask of valid pixels.
+func (d *lercDecoder) readMask() error {
+	h := &d.header
+	numBytesMask, ok := d.readInt32()
+	if !ok || numBytesMask < 0 || int(numBytesMask) > len(d.src) {
+		return errInvalidLERC
+	}
+	n := h.nRows * h.nCols
+	switch {
+	case h.numValidPixel == 0 || h.numValidPixel == n:
+		if numBytesMask != 0 {
+			return errInvalidLERC
+		}
+		return nil
+	case numBytesMask == 0:
+		// The mask would be the same as the previous blob's mask, which we
+		// do not have.
+		return errInvalidLERC
+	}
+
+	mask, err := decodeLERCRLE(d.src[:numBytesMask], (n+7)/8)
+	if err != nil {
+		return err
+	}
+	d.mask = mask
+	d.src = d.src[numBytesMask:]
+	return nil
+}
+
+// readTiles reads d's values, which are stored in micro blocks.
+func (d *lercDecoder) readTiles() error {
+	h := &d.header
+	for i0 := 0; i0 < h.nRows; i0 += h.microBlockSize {
+		i1 := min(i0+h.microBlockSize, h.nRows)
+		for j0 := 0; j0 < h.nCols; j0 += h.microBlockSize {
+			j1 := min(j0+h.microBlockSize, h.nCols)
+			if err := d.readTile(i0, i1, j0, j1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readTile reads the values in the micro block with rows i0 to i1 and columns
+// j0 to j1.
+func (d *lercDecoder) readTile(i0, i1, j0, j1 int) error {
+	h := &d.header
+	comprFlag, ok := d.readByte()
+	if !ok {
+		return errInvalidLERC
+	}
+	bits67 := int(comprFlag >> 6)
+	if h.version >= 5 {
+		if int(comprFlag>>3)&7 != (j0>>4)&7 {
+			return errInvalidLERC
+		}
+		// Bit 2 is set if values are stored as differences from the previous
+		// depth, which is not possible with a depth of one.
+		if comprFlag&4 != 0 {
+			return errInvalidLERC
+		}
+	} else if int(comprFlag>>2)&15 != (j0>>3)&15 {
+		return errInvalidLERC
+	}
+
+	// forEachValid calls f with the index of each valid pixel in the micro
+	// block.
+	forEachValid := func(f func(k int) bool) bool {
+		for i := i0; i < i1; i++ {
+			for j := j0; j < j1; j++ {
+				if k := i*h.nCols + j; d.isValid(k) {
+					if !f(k) {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	switch comprFlag & 3 {
+	case 0:
+		// Values are stored uncompressed.
+		if !forEachValid(func(k int) bool {
+			d.values[k], ok = d.readValue(h.dataType)
+			return ok
+		}) {
+			return errInvalidLERC
+		}
+	case 2:
+		// All values are zero.
+	default:
+		offset, ok := d.readValue(lercDataTypeUsed(h.dataType, bits67))
+		if !ok {
+			return errInvalidLERC
+		}
+		if comprFlag&3 == 3 {
+			// All values are equal to the offset.
+			forEachValid(func(k int) bool {
+				d.values[k] = offset
+				return true
+			})
+			return nil
+		}
+
+		numValid := 0
+		forEachValid(func(int) bool {
+			numValid++
+			return true
+		})
+		quantized, err := d.readBitStuffed(numValid)
+		if err != nil {
+			return err
+		}
+		if len(quantized) != numValid {
+			return errInvalidLERC
+		}
+		invScale := 2 * h.maxZError
+		forEachValid(func(k int) bool {
+			d.values[k] = min(offset+float64(quantized[0])*invScale, h.zMax)
+			quantized = quantized[1:]
+			return true
+		})
+	}
+	return nil
+}
+
+// readHuffman reads d's Huffman-encoded 8-bit values. If delta is true then
+// each value is stored as the difference from the previous valid value in
+// the same row or, for the first valid value in a row, from the valid value
+// above it.
+func (d *lercDecoder) readHuffman(delta bool) error {
+	h := &d.header
+	root, err := d.readHuffmanCodeTable()
+	if err != nil {
+		return err
+	}
+
+	// Char values are offset so that they are non-negative.
+	var offset byte
+	if h.dataType == lercDataTypeChar {
+		offset = 128
+	}
+
+	br := lercBitReader{src: d.src}
+	prevValues := make([]byte, h.nRows*h.nCols)
+	var prevValue byte
+	for i := range h.nRows {
+		for j := range h.nCols {
+			k := i*h.nCols + j
+			if !d.isValid(k) {
+				continue
+			}
+			symbol, ok := root.decode(&br)
+			if !ok {
+				return errInvalidLERC
+			}
+			value := byte(symbol) - offset
+			if delta {
+				switch {
+				case j > 0 && d.isValid(k-1):
+					value += prevValue
+				case i > 0 && d.isValid(k-h.nCols):
+					value += prevValues[k-h.nCols]
+				default:
+					value += prevValue
+				}
+			}
+			prevValues[k] = value
+			prevValue = value
+			if h.dataType == lercDataTypeChar {
+				d.values[k] = float64(int8(value))
+			} else {
+				d.values[k] = float64(value)
+			}
+		}
+	}
+
+	// The encoder writes an extra word, as its decoder may read ahead.
+	numBytes := 4 * (br.wordsRead() + 1)
+	if len(d.src) < numBytes {
+		return errInvalidLERC
+	}
+	d.src = d.src[numBytes:]
+	return nil
+}
+
+// readHuffmanCodeTable reads a Huffman code table and returns the root of its
+// decoding tree.
+func (d *lercDecoder) readHuffmanCodeTable() (*lercHuffmanNode, error) {
+	var ints [4]int
+	for i := range ints {
+		value, ok := d.readInt32()
+		if !ok {
+			return nil, errInvalidLERC
+		}
+		ints[i] = int(value)
+	}
+	version, size, i0, i1 := ints[0], ints[1], ints[2], ints[3]
+	switch {
+	case version < lercHuffmanMinVersion:
+		return nil, errInvalidLERC
+	case size <= 0 || size > 1<<16:
+		return nil, errInvalidLERC
+	case i0 < 0 || i0 >= i1 || i0 >= size || i1-i0 > size:
+		return nil, errInvalidLERC
+	}
+
+	codeLengths, err := d.readBitStuffed(i1 - i0)
+	if err != nil {
+		return nil, err
+	}
+	if len(codeLengths) != i1-i0 {
+		return nil, errInvalidLERC
+	}
+
+	// The codes are stored in the order of the code lengths, most significant
+	// bit first.
+	root := &lercHuffmanNode{symbol: -1}
+	br := lercBitReader{src: d.src}
+	for i, codeLength := range codeLengths {
+		if codeLength == 0 {
+			continue
+		}
+		if codeLength > 32 {
+			return nil, errInvalidLERC
+		}
+		code, ok := br.readBits(int(codeLength))
+		if !ok {
+			return nil, errInvalidLERC
+		}
+		symbol := i0 + i
+		if symbol >= size {
+			symbol -= size
+		}
+		if !root.insert(code, int(codeLength), symbol) {
+			return nil, errInvalidLERC
+		}
+	}
+	d.src = d.src[4*br.wordsRead():]
+	return root, nil
+}
+
+// readBitStuffed reads an array of at most maxElements bit stuffed unsigned
+// integers.
+func (d *lercDecoder) readBitStuffed(maxElements int) ([]uint32, error) {
+	numBitsByte, ok := d.readByte()
+	if !ok {
+		return nil, errInvalidLERC
+	}
+	var numElementsSize int
+	switch numBitsByte >> 6 {
+	case 0:
+		numElementsSize = 4
+	case 1:
+		numElementsSize = 2
+	case 2:
+		numElementsSize = 1
+	default:
+		return nil, errInvalidLERC
+	}
+	doLUT := numBitsByte&(1<<5) != 0
+	numBits := int(numBitsByte & 31)
+	if len(d.src) < numElementsSize {
+		return nil, errInvalidLERC
+	}
+	numElements := 0
+	for i := range numElementsSize {
+		numElements |= int(d.src[i]) << (8 * i)
+	}
+	d.src = d.src[numElementsSize:]
+	if numElements > maxElements {
+		return nil, errInvalidLERC
+	}
+
+	if !doLUT {
+		if numBits == 0 {
+			return make([]uint32, numElements), nil
+		}
+		return d.bitUnstuff(numElements, numBits)
+	}
+
+	nLUTByte, ok := d.readByte()
+	if !ok || nLUTByte == 0 {
+		return nil, errInvalidLERC
+	}
+	nLUT := int(nLUTByte) - 1
+	lut, err := d.bitUnstuff(nLUT, numBits)
+	if err != nil {
+		return nil, err
+	}
+	nBitsLUT := bits.Len(uint(nLUT))
+	if nBitsLUT == 0 {
+		return nil, errInvalidLERC
+	}
+	indexes, err := d.bitUnstuff(numElements, nBitsLUT)
+	if err != nil {
+		return nil, err
+	}
+	lut = append([]uint32{0}, lut...)
+	for i, index := range indexes {
+		if int(index) >= len(lut) {
+			return nil, errInvalidLERC
+		}
+		indexes[i] = lut[index]
+	}
+	return indexes, nil
+}
+
+// bitUnstuff reads numElements unsigned integers of numBits bits each.
+func (d *lercDecoder) bitUnstuff(numElements, numBits int) ([]uint32, error) {
+	if numBits > 32 {
+		return nil, errInvalidLERC
+	}
+	numUInts := (numElements*numBits + 31) / 32
+	numBytesTail := ((numElements * numBits & 31) + 7) / 8
+	numBytes := 4 * numUInts
+	if numBytesTail > 0 {
+		numBytes -= 4 - numBytesTail
+	}
+	if len(d.src) < numBytes {
+		return nil, errInvalidLERC
+	}
+	words := make([]uint32, numUInts)
+	buf := make([]byte, 4*numUInts)
+	copy(buf, d.src[:numBytes])
+	d.src = d.src[numBytes:]
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+
+	values := make([]uint32, numElements)
+	if d.header.version >= 3 {
+		// Values are packed starting with the least significant bits.
+		for i := range values {
+			bitPos := i * numBits
+			word, shift := bitPos/32, bitPos%32
+			value := uint64(words[word]) >> shift
+			if shift+numBits > 32 {
+				value |= uint64(words[word+1]) << (32 - shift)
+			}
+			values[i] = uint32(value & (1<<numBits - 1))
+		}
+	} else {
+		// Values are packed starting with the most significant bits, and the
+		// tail bytes are stored in the least significant bytes of the last
+		// word.
+		if numBytesTail > 0 {
+			words[numUInts-1] <<= 8 * (4 - numBytesTail)
+		}
+		for i := range values {
+			bitPos := i * numBits
+			word, shift := bitPos/32, bitPos%32
+			value := uint64(words[word]) << 32
+			if shift+numBits > 32 {
+				value |= uint64(words[word+1])
+			}
+			values[i] = uint32(value << shift >> (64 - numBits))
+		}
+	}
+	return values, nil
+}
+
+// A lercBitReader reads bits, most significant bit first, from little-endian
+// 32-bit words.
+type lercBitReader struct {
+	src    []byte
+	word   int // Index of the current word.
+	bitPos int // Number of bits read from the current word.
+}
+
+// readBit reads a single bit.
+func (br *lercBitReader) readBit() (uint32, bool) {
+	if len(br.src) < 4*(br.word+1) {
+		return 0, false
+	}
+	bit := binary.LittleEndian.Uint32(br.src[4*br.word:]) >> (31 - br.bitPos) & 1
+	br.bitPos++
+	if br.bitPos == 32 {
+		br.word++
+		br.bitPos = 0
+	}
+	return bit, true
+}
+
+// readBits reads numBits bits.
+func (br *lercBitReader) readBits(numBits int) (uint32, bool) {
+	var value uint32
+	for range numBits {
+		bit, ok := br.readBit()
+		if !ok {
+			return 0, false
+		}
+		value = value<<1 | bit
+	}
+	return value, true
+}
+
+// wordsRead returns the number of words that have been at least partially
+// read.
+func (br *lercBitReader) wordsRead() int {
+	if br.bitPos > 0 {
+		return br.word + 1
+	}
+	return br.word
+}
+
+// A lercHuffmanNode is a node in a Huffman decoding tree. Leaf nodes have a
+// non-negative symbol.
+type lercHuffmanNode struct {
+	children [2]*lercHuffmanNode
+	symbol   int
+}
+
+// insert inserts symbol with the codeLength-bit code into the tree rooted at
+// n. It returns false if the code conflicts with an existing code.
+func (n *lercHuffmanNode) insert(code uint32, codeLength, symbol int) bool {
+	for i := codeLength - 1; i >= 0; i-- {
+		if n.symbol >= 0 {
+			return false
+		}
+		bit := code >> i & 1
+		if n.children[bit] == nil {
+			n.children[bit] = &lercHuffmanNode{symbol: -1}
+		}
+		n = n.children[bit]
+	}
+	if n.symbol >= 0 || n.children[0] != nil || n.children[1] != nil {
+		return false
+	}
+	n.symbol = symbol
+	return true
+}
+
+// decode decodes a single symbol from br using the tree rooted at n.
+func (n *lercHuffmanNode) decode(br *lercBitReader) (int, bool) {
+	for n.symbol < 0 {
+		bit, ok := br.readBit()
+		if !ok {
+			return 0, false
+		}
+		if n = n.children[bit]; n == nil {
+			return 0, false
+		}
+	}
+	return n.symbol, true
+}
+
+// fill sets all valid values to value.
+func (d *lercDecoder) fill(value float64) {
+	for k := range d.values {
+		if d.isValid(k) {
+			d.values[k] = value
+		}
+	}
+}
+
+// isValid returns whether the kth pixel is valid.
+func (d *lercDecoder) isValid(k int) bool {
+	switch {
+	case d.header.numValidPixel == 0:
+		return false
+	case d.mask == nil:
+		return true
+	default:
+		return d.mask[k>>3]&(128>>(k&7)) != 0
+	}
+}
+
+// readByte reads a single byte.
+func (d *lercDecoder) readByte() (byte, bool) {
+	if len(d.src) < 1 {
+		return 0, false
+	}
+	b := d.src[0]
+	d.src = d.src[1:]
+	return b, true
+}
+
+// readInt32 reads a little-endian int32.
+func (d *lercDecoder) readInt32() (int32, bool) {
+	if len(d.src) < 4 {
+		return 0, false
+	}
+	value := int32(binary.LittleEndian.Uint32(d.src))
+	d.src = d.src[4:]
+	return value, true
+}
+
+// readValue reads a single value of dataType.
+func (d *lercDecoder) readValue(dataType int) (float64, bool) {
+	size := lercDataTypeSizes[dataType]
+	if len(d.src) < size {
+		return 0, false
+	}
+	value := lercValue(d.src, dataType)
+	d.src = d.src[size:]
+	return value, true
+}
+
+// write writes d's values to dst in little-endian byte order.
+func (d *lercDecoder) write(dst []byte) error {
+	dataType := d.header.dataType
+	size := lercDataTypeSizes[dataType]
+	if len(dst) > size*len(d.values) {
+		return io.ErrUnexpectedEOF
+	}
+	for k := range len(dst) / size {
+		value := d.values[k]
+		if !d.isValid(k) && (dataType == lercDataTypeFloat || dataType == lercDataTypeDouble) {
+			value = math.NaN()
+		}
+		b := dst[k*size:]
+		switch dataType {
+		case lercDataTypeChar:
+			b[0] = byte(int8(value))
+		case lercDataTypeByte:
+			b[0] = byte(value)
+		case lercDataTypeShort:
+			binary.LittleEndian.PutUint16(b, uint16(int16(value)))
+		case lercDataTypeUShort:
+			binary.LittleEndian.PutUint16(b, uint16(value))
+		case lercDataTypeInt:
+			binary.LittleEndian.PutUint32(b, uint32(int32(value)))
+		case lercDataTypeUInt:
+			binary.LittleEndian.PutUint32(b, uint32(value))
+		case lercDataTypeFloat:
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(value)))
+		case lercDataTypeDouble:
+			binary.LittleEndian.PutUint64(b, math.Float64bits(value))
+		}
+	}
+	return nil
+}
+
+// decodeLERCRLE decodes LERC run length encoded data into a slice of size
+// bytes. The data consists of runs, each starting with a little-endian int16
+// count. Positive counts are followed by count literal bytes, negative counts
+// by a single byte that is repeated -count times. A count of -32768 marks the
+// end of the data.
+func decodeLERCRLE(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+	for {
+		if len(src) < 2 {
+			return nil, errInvalidLERC
+		}
+		count := int(int16(binary.LittleEndian.Uint16(src)))
+		src = src[2:]
+		switch {
+		case count == math.MinInt16:
+			if len(dst) != size {
+				return nil, errInvalidLERC
+			}
+			return dst, nil
+		case count > 0:
+			if len(src) < count || len(dst)+count > size {
+				return nil, errInvalidLERC
+			}
+			dst = append(dst, src[:count]...)
+			src = src[count:]
+		default:
+			if len(src) < 1 || len(dst)-count > size {
+				return nil, errInvalidLERC
+			}
+			for range -count {
+				dst = append(dst, src[0])
+			}
+			src = src[1:]
+		}
+	}
+}
+
+// isZlibHeader returns whether data starts with a zlib header using the
+// DEFLATE compression method.
+func isZlibHeader(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// lercDataTypeUsed returns the data type used to store an offset of dataType,
+// reduced by typeCode to save space.
+func lercDataTypeUsed(dataType, typeCode int) int {
+	switch dataType {
+	case lercDataTypeShort, lercDataTypeInt:
+		return dataType - typeCode
+	case lercDataTypeUShort, lercDataTypeUInt:
+		return dataType - 2*typeCode
+	case lercDataTypeFloat:
+		switch typeCode {
+		case 0:
+			return dataType
+		case 1:
+			return lercDataTypeShort
+		default:
+			return lercDataTypeByte
+		}
+	case lercDataTypeDouble:
+		if typeCode == 0 {
+			return dataType
+		}
+		return dataType - 2*typeCode + 1
+	default:
+		return dataType
+	}
+}
+
+// lercValue returns the little-endian value of dataType at the start of data.
+func lercValue(data []byte, dataType int) float64 {
+	switch dataType {
+	case lercDataTypeChar:
+		return float64(int8(data[0]))
+	case lercDataTypeByte:
+		return float64(data[0])
+	case lercDataTypeShort:
+		return float64(int16(binary.LittleEndian.Uint16(data)))
+	case lercDataTypeUShort:
+		return float64(binary.LittleEndian.Uint16(data))
+	case lercDataTypeInt:
+		return float64(int32(binary.LittleEndian.Uint32(data)))
+	case lercDataTypeUInt:
+		return float64(binary.LittleEndian.Uint32(data))
+	case lercDataTypeFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	default:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data))
+	}
+}
+
+// lercChecksum returns the Fletcher-32 checksum used by Lerc2.
+func lercChecksum(data []byte) uint32 {
+	sum1, sum2 := uint32(0xffff), uint32(0xffff)
+	for len(data) >= 2 {
+		n := min(len(data)/2, 359)
+		for range n {
+			sum1 += uint32(data[0]) << 8
+			sum1 += uint32(data[1])
+			sum2 += sum1
+			data = data[2:]
+		}
+		sum1 = sum1&0xffff + sum1>>16
+		sum2 = sum2&0xffff + sum2>>16
+	}
+	if len(data) == 1 {
+		sum1 += uint32(data[0]) << 8
+		sum2 += sum1
+	}
+	sum1 = sum1&0xffff + sum1>>16
+	sum2 = sum2&0xffff + sum2>>16
+	return sum2<<16 | sum1
+}
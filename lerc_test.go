@@ -0,0 +1,314 @@
+package elevation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDecompressLERC(t *testing.T) {
+	// The test data were generated with libLerc, using each Lerc2 version
+	// and 20 rows of 33 columns. The tolerance is the maximum error passed to
+	// the encoder. The masked data sets mark every seventh pixel as invalid.
+	const (
+		nRows = 20
+		nCols = 33
+	)
+	for _, tc := range []struct {
+		dataType  string
+		size      int
+		value     func(dst []byte) float64
+		sample    func(i, j int) float64
+		tolerance float64
+		masked    bool
+	}{
+		{
+			dataType: "float32",
+			size:     4,
+			value: func(dst []byte) float64 {
+				return float64(math.Float32frombits(binary.LittleEndian.Uint32(dst)))
+			},
+			sample: func(i, j int) float64 {
+				return 1234.5 + 0.37*float64(i) - 0.11*float64(j)
+			},
+			tolerance: 0.01 + 1e-3, // Allow for float32 rounding.
+			masked:    true,
+		},
+		{
+			dataType: "float64",
+			size:     8,
+			value: func(dst []byte) float64 {
+				return math.Float64frombits(binary.LittleEndian.Uint64(dst))
+			},
+			sample: func(i, j int) float64 {
+				return []float64{0, 200, 250, 1000, -7}[(i*j+i)%5]
+			},
+			tolerance: 0.5,
+			masked:    true,
+		},
+		{
+			dataType: "int16",
+			size:     2,
+			value: func(dst []byte) float64 {
+				return float64(int16(binary.LittleEndian.Uint16(dst)))
+			},
+			sample: func(i, j int) float64 {
+				return []float64{0, float64(200 + (i*nCols+j)%17), 5, float64(-300 + j)}[(i/8+j/8)%4]
+			},
+			tolerance: 0.5,
+		},
+		{
+			dataType: "uint8",
+			size:     1,
+			value: func(dst []byte) float64 {
+				return float64(dst[0])
+			},
+			sample: func(i, j int) float64 {
+				return min(max(-20+3*float64(i)+7*float64(j), 0), 255)
+			},
+			tolerance: 2,
+		},
+	} {
+		for version := lercMinVersion; version <= lercMaxVersion; version++ {
+			name := fmt.Sprintf("v%d_%s", version, tc.dataType)
+			t.Run(name, func(t *testing.T) {
+				src, err := os.ReadFile(filepath.Join("testdata", "lerc", name+".lerc2"))
+				assert.NoError(t, err)
+
+				for _, compress := range []func([]byte) []byte{
+					nil,
+					mustCompress(compressDeflate),
+					mustCompress(compressZSTD),
+				} {
+					compressedSrc := src
+					if compress != nil {
+						compressedSrc = compress(src)
+					}
+					dst := make([]byte, nRows*nCols*tc.size)
+					assert.NoError(t, decompressLERC(dst, compressedSrc))
+					for i := range nRows {
+						for j := range nCols {
+							k := i*nCols + j
+							actual := tc.value(dst[k*tc.size:])
+							switch {
+							case tc.masked && k%7 == 3:
+								assert.True(t, math.IsNaN(actual))
+							default:
+								assert.True(t, math.Abs(actual-tc.sample(i, j)) <= tc.tolerance,
+									"i=%d j=%d actual=%f", i, j, actual)
+							}
+						}
+					}
+				}
+
+				// A short destination is allowed, a long one is not.
+				assert.NoError(t, decompressLERC(make([]byte, tc.size), src))
+				assert.IsError(t, decompressLERC(make([]byte, (nRows*nCols+1)*tc.size), src), io.ErrUnexpectedEOF)
+
+				// Truncated and corrupt blobs are errors.
+				assert.Error(t, decompressLERC(make([]byte, tc.size), src[:len(src)-1]))
+				if version >= lercChecksumVersion {
+					corruptSrc := bytes.Clone(src)
+					corruptSrc[len(corruptSrc)-1] ^= 1
+					assert.IsError(t, decompressLERC(make([]byte, tc.size), corruptSrc), errLERCChecksum)
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressLERC_Lossless(t *testing.T) {
+	// The test data were generated with libLerc with 20 rows of 33 columns
+	// and the maximum errors that GDAL uses for lossless compression: 0.5 for
+	// 8-bit data, which is Huffman encoded, and 0 for floating point data,
+	// which is losslessly compressed. The masked data sets mark every seventh
+	// pixel as invalid.
+	const (
+		nRows = 20
+		nCols = 33
+	)
+	for _, tc := range []struct {
+		name   string
+		size   int
+		value  func(dst []byte) float64
+		sample func(i, j int) float64
+		masked bool
+	}{
+		{
+			name: "v4_uint8_huffman",
+			size: 1,
+			value: func(dst []byte) float64 {
+				return float64(dst[0])
+			},
+			sample: func(i, j int) float64 {
+				return float64((3*i + 7*j) % 256)
+			},
+		},
+		{
+			name: "v6_int8_huffman",
+			size: 1,
+			value: func(dst []byte) float64 {
+				return float64(int8(dst[0]))
+			},
+			sample: func(i, j int) float64 {
+				return []float64{-100, -3, 0, 5, 42}[(i*j+i)%5]
+			},
+			masked: true,
+		},
+		{
+			name: "v6_float32_lossless",
+			size: 4,
+			value: func(dst []byte) float64 {
+				return float64(math.Float32frombits(binary.LittleEndian.Uint32(dst)))
+			},
+			sample: func(i, j int) float64 {
+				return 1000 + float64(i*j)/16
+			},
+			masked: true,
+		},
+		{
+			name: "v6_float64_lossless",
+			size: 8,
+			value: func(dst []byte) float64 {
+				return math.Float64frombits(binary.LittleEndian.Uint64(dst))
+			},
+			sample: func(i, j int) float64 {
+				if j%11 == 0 {
+					return -9999
+				}
+				return float64(100*i+j) / 3
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", "lerc", tc.name+".lerc2"))
+			assert.NoError(t, err)
+
+			dst := make([]byte, nRows*nCols*tc.size)
+			assert.NoError(t, decompressLERC(dst, src))
+			for i := range nRows {
+				for j := range nCols {
+					k := i*nCols + j
+					actual := tc.value(dst[k*tc.size:])
+					switch {
+					case tc.masked && k%7 == 3 && tc.size == 1:
+						assert.Equal(t, 0, actual, "i=%d j=%d", i, j)
+					case tc.masked && k%7 == 3:
+						assert.True(t, math.IsNaN(actual), "i=%d j=%d", i, j)
+					default:
+						assert.Equal(t, tc.sample(i, j), actual, "i=%d j=%d", i, j)
+					}
+				}
+			}
+
+			// Truncated and corrupt blobs are errors.
+			assert.Error(t, decompressLERC(make([]byte, tc.size), src[:len(src)-1]))
+			corruptSrc := bytes.Clone(src)
+			corruptSrc[len(corruptSrc)-1] ^= 1
+			assert.IsError(t, decompressLERC(make([]byte, tc.size), corruptSrc), errLERCChecksum)
+		})
+	}
+}
+
+func TestDecompressLERC_Unsupported(t *testing.T) {
+	// Lerc1 blobs are not supported.
+	assert.IsError(t, decompressLERC(make([]byte, 1), []byte("CntZImage ")), errors.ErrUnsupported)
+}
+
+func TestDecompressLERC_RoundTrip(t *testing.T) {
+	for _, samples := range [][]float32{
+		{1},
+		{1, 1, 1, 1},
+		{1, 2, 3, math.MaxFloat32, -math.MaxFloat32, 0.5},
+	} {
+		src := make([]byte, 0, 4*len(samples))
+		for _, sample := range samples {
+			src = binary.LittleEndian.AppendUint32(src, math.Float32bits(sample))
+		}
+		dst := make([]byte, len(src))
+		assert.NoError(t, decompressLERC(dst, compressLERCFloat32(src)))
+		assert.Equal(t, src, dst)
+	}
+}
+
+func TestDecodeLERCRLE(t *testing.T) {
+	src := []byte{
+		0x02, 0x00, 0x01, 0x02, // Two literal bytes.
+		0xfd, 0xff, 0xff, // Three repeated bytes.
+		0x00, 0x80, // End of data.
+	}
+	dst, err := decodeLERCRLE(src, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x02, 0xff, 0xff, 0xff}, dst)
+
+	_, err = decodeLERCRLE(src, 4)
+	assert.IsError(t, err, errInvalidLERC)
+	_, err = decodeLERCRLE(src, 6)
+	assert.IsError(t, err, errInvalidLERC)
+	_, err = decodeLERCRLE(src[:len(src)-1], 5)
+	assert.IsError(t, err, errInvalidLERC)
+}
+
+func TestDecodePackBits(t *testing.T) {
+	src := []byte{
+		0x01, 0x01, 0x02, // Two literal bytes.
+		0x81, 0xff, // Three repeated bytes.
+	}
+	dst, err := decodePackBits(src, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x02, 0xff, 0xff, 0xff}, dst)
+
+	_, err = decodePackBits(src, 4)
+	assert.IsError(t, err, errInvalidLERC)
+	_, err = decodePackBits(src, 6)
+	assert.IsError(t, err, errInvalidLERC)
+	_, err = decodePackBits(src[:len(src)-1], 5)
+	assert.IsError(t, err, errInvalidLERC)
+}
+
+// compressLERCFloat32 returns a lossless Lerc2 version 3 blob containing the
+// little-endian float32 samples in data, stored as a single row of
+// uncompressed values.
+func compressLERCFloat32(data []byte) []byte {
+	n := len(data) / 4
+	zMin, zMax := math.Inf(1), math.Inf(-1)
+	for k := range n {
+		sample := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*k:])))
+		zMin = min(zMin, sample)
+		zMax = max(zMax, sample)
+	}
+
+	blob := append([]byte{}, lercFileKey...)
+	blob = binary.LittleEndian.AppendUint32(blob, 3) // Version.
+	blob = binary.LittleEndian.AppendUint32(blob, 0) // Checksum.
+	for _, value := range []int{
+		1,                 // nRows.
+		n,                 // nCols.
+		n,                 // numValidPixel.
+		8,                 // microBlockSize.
+		0,                 // blobSize.
+		lercDataTypeFloat, // dataType.
+	} {
+		blob = binary.LittleEndian.AppendUint32(blob, uint32(value))
+	}
+	for _, value := range []float64{0, zMin, zMax} {
+		blob = binary.LittleEndian.AppendUint64(blob, math.Float64bits(value))
+	}
+	blob = binary.LittleEndian.AppendUint32(blob, 0) // numBytesMask.
+	if zMin != zMax {
+		blob = append(blob, 1) // readDataOneSweep.
+		blob = append(blob, data[:4*n]...)
+	}
+
+	binary.LittleEndian.PutUint32(blob[30:], uint32(len(blob)))
+	binary.LittleEndian.PutUint32(blob[10:], lercChecksum(blob[14:]))
+	return blob
+}
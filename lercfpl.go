@@ -0,0 +1,205 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Lossless floating point (FPL) compression, used by Lerc2 version 6 for
+// floating point data with a maximum error of zero.
+//
+// Each value is split into its bytes and each byte position is stored as a
+// separate plane of nRows*nCols bytes. Each plane is delta encoded a number of
+// times and then compressed. The values themselves are optionally delta
+// encoded, along rows or across both rows and columns, before being split. The
+// exponent and mantissa of values are delta encoded independently. Floats are
+// transformed before encoding so that the sign bit lies between the exponent
+// and the mantissa.
+
+// Lerc2 FPL predictors.
+const (
+	lercFPLPredictorNone = iota
+	lercFPLPredictorDelta
+	lercFPLPredictorCross
+)
+
+// Lerc2 FPL plane compression modes.
+const (
+	lercFPLPlaneHuffman = iota
+	lercFPLPlaneConstant
+	lercFPLPlaneRaw
+	lercFPLPlanePackBits
+)
+
+// lercFPLMaxLevel is the maximum number of times that a plane is delta
+// encoded.
+const lercFPLMaxLevel = 5
+
+// readFPL reads d's lossless floating point values.
+func (d *lercDecoder) readFPL() error {
+	h := &d.header
+	size := lercDataTypeSizes[h.dataType]
+	n := h.nRows * h.nCols
+
+	predictor, ok := d.readByte()
+	if !ok || predictor > lercFPLPredictorCross {
+		return errInvalidLERC
+	}
+
+	data := make([]byte, size*n)
+	for range size {
+		if len(d.src) < 6 {
+			return errInvalidLERC
+		}
+		byteIndex := int(d.src[0])
+		level := int(d.src[1])
+		planeSize := int(binary.LittleEndian.Uint32(d.src[2:]))
+		d.src = d.src[6:]
+		if byteIndex >= size || level > lercFPLMaxLevel || planeSize > len(d.src) {
+			return errInvalidLERC
+		}
+		plane, err := d.decodeFPLPlane(d.src[:planeSize], n)
+		if err != nil {
+			return err
+		}
+		d.src = d.src[planeSize:]
+		for l := level; l > 0; l-- {
+			for i := l; i < n; i++ {
+				plane[i] += plane[i-1]
+			}
+		}
+		for i, b := range plane {
+			data[i*size+byteIndex] = b
+		}
+	}
+
+	// The exponent, including the sign bit for floats, and the mantissa are
+	// added separately.
+	words := make([]uint64, n)
+	var mantissaBits int
+	switch h.dataType {
+	case lercDataTypeFloat:
+		for i := range words {
+			words[i] = uint64(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		mantissaBits = 23
+	default:
+		for i := range words {
+			words[i] = binary.LittleEndian.Uint64(data[8*i:])
+		}
+		mantissaBits = 52
+	}
+	mantissaMask := uint64(1)<<mantissaBits - 1
+	add := func(a, b uint64) uint64 {
+		return (a>>mantissaBits+b>>mantissaBits)<<mantissaBits | (a+b)&mantissaMask
+	}
+	if predictor == lercFPLPredictorCross {
+		for i := 1; i < h.nRows; i++ {
+			for j := range h.nCols {
+				k := i*h.nCols + j
+				words[k] = add(words[k], words[k-h.nCols])
+			}
+		}
+	}
+	if predictor != lercFPLPredictorNone {
+		for i := range h.nRows {
+			for j := 1; j < h.nCols; j++ {
+				k := i*h.nCols + j
+				words[k] = add(words[k], words[k-1])
+			}
+		}
+	}
+
+	for k, word := range words {
+		switch h.dataType {
+		case lercDataTypeFloat:
+			// Move the sign bit from bit 23 back to bit 31.
+			word := uint32(word)
+			word = word>>24<<23 | word>>23&1<<31 | word&(1<<23-1)
+			d.values[k] = float64(math.Float32frombits(word))
+		default:
+			d.values[k] = math.Float64frombits(word)
+		}
+	}
+	return nil
+}
+
+// decodeFPLPlane decodes a compressed plane of n bytes from src.
+func (d *lercDecoder) decodeFPLPlane(src []byte, n int) ([]byte, error) {
+	if len(src) < 1 {
+		return nil, errInvalidLERC
+	}
+	mode, src := src[0], src[1:]
+	switch mode {
+	case lercFPLPlaneHuffman:
+		pd := &lercDecoder{
+			src:    src,
+			header: d.header,
+		}
+		root, err := pd.readHuffmanCodeTable()
+		if err != nil {
+			return nil, err
+		}
+		br := lercBitReader{src: pd.src}
+		plane := make([]byte, n)
+		for i := range plane {
+			symbol, ok := root.decode(&br)
+			if !ok || symbol > math.MaxUint8 {
+				return nil, errInvalidLERC
+			}
+			plane[i] = byte(symbol)
+		}
+		return plane, nil
+	case lercFPLPlaneConstant:
+		if len(src) < 5 || int(binary.LittleEndian.Uint32(src[1:])) != n {
+			return nil, errInvalidLERC
+		}
+		plane := make([]byte, n)
+		for i := range plane {
+			plane[i] = src[0]
+		}
+		return plane, nil
+	case lercFPLPlaneRaw:
+		if len(src) < n {
+			return nil, errInvalidLERC
+		}
+		return append([]byte(nil), src[:n]...), nil
+	case lercFPLPlanePackBits:
+		return decodePackBits(src, n)
+	default:
+		return nil, errInvalidLERC
+	}
+}
+
+// decodePackBits decodes PackBits run length encoded data into a slice of
+// size bytes. Each run starts with a count byte. Counts less than 128 are
+// followed by count+1 literal bytes, larger counts by a single byte that is
+// repeated count-126 times.
+func decodePackBits(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		count := int(src[0])
+		src = src[1:]
+		if count < 128 {
+			count++
+			if len(src) < count || len(dst)+count > size {
+				return nil, errInvalidLERC
+			}
+			dst = append(dst, src[:count]...)
+			src = src[count:]
+		} else {
+			count -= 126
+			if len(src) < 1 || len(dst)+count > size {
+				return nil, errInvalidLERC
+			}
+			for range count {
+				dst = append(dst, src[0])
+			}
+			src = src[1:]
+		}
+	}
+	if len(dst) != size {
+		return nil, errInvalidLERC
+	}
+	return dst, nil
+}
@@ -0,0 +1,17 @@
+package elevation
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapFile maps the first size bytes of file into memory, read only.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	return unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+}
+
+// munmap unmaps data, which must have been returned by mmapFile.
+func munmap(data []byte) error {
+	return unix.Munmap(data)
+}
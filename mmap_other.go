@@ -0,0 +1,19 @@
+//go:build !linux
+
+package elevation
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile returns errors.ErrUnsupported as memory mapping is only supported
+// on Linux.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// munmap does nothing.
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TIFF predictors.
+const (
+	predictorNone          = 1
+	predictorHorizontal    = 2
+	predictorFloatingPoint = 3
+)
+
+// undoHorizontalDifferencing undoes TIFF horizontal differencing in place.
+// data contains rows of width pixels of samplesPerPixel samples of
+// bytesPerSample bytes each, stored in byteOrder. Each sample is differenced
+// with the same sample of the previous pixel.
+func undoHorizontalDifferencing(data []byte, width, samplesPerPixel, bytesPerSample int, byteOrder binary.ByteOrder) error {
+	n := width * samplesPerPixel
+	rowSize := n * bytesPerSample
+	for row := data; len(row) >= rowSize; row = row[rowSize:] {
+		switch bytesPerSample {
+		case 1:
+			for i := samplesPerPixel; i < n; i++ {
+				row[i] += row[i-samplesPerPixel]
+			}
+		case 2:
+			for i := samplesPerPixel; i < n; i++ {
+				byteOrder.PutUint16(row[2*i:], byteOrder.Uint16(row[2*i:])+byteOrder.Uint16(row[2*(i-samplesPerPixel):]))
+			}
+		case 4:
+			for i := samplesPerPixel; i < n; i++ {
+				byteOrder.PutUint32(row[4*i:], byteOrder.Uint32(row[4*i:])+byteOrder.Uint32(row[4*(i-samplesPerPixel):]))
+			}
+		case 8:
+			for i := samplesPerPixel; i < n; i++ {
+				byteOrder.PutUint64(row[8*i:], byteOrder.Uint64(row[8*i:])+byteOrder.Uint64(row[8*(i-samplesPerPixel):]))
+			}
+		default:
+			return fmt.Errorf("horizontal differencing with %d bytes per sample: %w", bytesPerSample, errors.ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// undoFloatingPointPredictor undoes the TIFF floating point predictor in
+// place. data contains rows of width pixels of samplesPerPixel samples of
+// bytesPerSample bytes each. The floating point predictor splits each row into
+// byte planes, most significant byte first, and then applies horizontal
+// differencing to the bytes with a stride of samplesPerPixel. The restored
+// samples are stored in byteOrder.
+func undoFloatingPointPredictor(data []byte, width, samplesPerPixel, bytesPerSample int, byteOrder binary.ByteOrder) error {
+	if bytesPerSample != 4 && bytesPerSample != 8 {
+		return fmt.Errorf("floating point predictor with %d bytes per sample: %w", bytesPerSample, errors.ErrUnsupported)
+	}
+	n := width * samplesPerPixel
+	rowSize := n * bytesPerSample
+	planes := make([]byte, rowSize)
+	for row := data; len(row) >= rowSize; row = row[rowSize:] {
+		copy(planes, row[:rowSize])
+		for i := samplesPerPixel; i < rowSize; i++ {
+			planes[i] += planes[i-samplesPerPixel]
+		}
+		for i := range n {
+			sample := row[i*bytesPerSample : (i+1)*bytesPerSample]
+			for plane := range bytesPerSample {
+				if byteOrder == binary.BigEndian {
+					sample[plane] = planes[plane*n+i]
+				} else {
+					sample[bytesPerSample-1-plane] = planes[plane*n+i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyHorizontalDifferencing returns a copy of data with TIFF horizontal
+// differencing applied.
+func applyHorizontalDifferencing(data []byte, width, samplesPerPixel, bytesPerSample int, byteOrder binary.ByteOrder) []byte {
+	result := make([]byte, len(data))
+	n := width * samplesPerPixel
+	rowSize := n * bytesPerSample
+	for rowStart := 0; rowStart < len(data); rowStart += rowSize {
+		row := data[rowStart : rowStart+rowSize]
+		resultRow := result[rowStart : rowStart+rowSize]
+		copy(resultRow[:samplesPerPixel*bytesPerSample], row)
+		for i := samplesPerPixel; i < n; i++ {
+			j, k := i*bytesPerSample, (i-samplesPerPixel)*bytesPerSample
+			switch bytesPerSample {
+			case 1:
+				resultRow[j] = row[j] - row[k]
+			case 2:
+				byteOrder.PutUint16(resultRow[j:], byteOrder.Uint16(row[j:])-byteOrder.Uint16(row[k:]))
+			case 4:
+				byteOrder.PutUint32(resultRow[j:], byteOrder.Uint32(row[j:])-byteOrder.Uint32(row[k:]))
+			case 8:
+				byteOrder.PutUint64(resultRow[j:], byteOrder.Uint64(row[j:])-byteOrder.Uint64(row[k:]))
+			}
+		}
+	}
+	return result
+}
+
+// applyFloatingPointPredictor returns a copy of data with the TIFF floating
+// point predictor applied.
+func applyFloatingPointPredictor(data []byte, width, samplesPerPixel, bytesPerSample int, byteOrder binary.ByteOrder) []byte {
+	result := make([]byte, len(data))
+	n := width * samplesPerPixel
+	rowSize := n * bytesPerSample
+	for rowStart := 0; rowStart < len(data); rowStart += rowSize {
+		row := data[rowStart : rowStart+rowSize]
+		planes := result[rowStart : rowStart+rowSize]
+		for i := range n {
+			for plane := range bytesPerSample {
+				if byteOrder == binary.BigEndian {
+					planes[plane*n+i] = row[i*bytesPerSample+plane]
+				} else {
+					planes[plane*n+i] = row[i*bytesPerSample+bytesPerSample-1-plane]
+				}
+			}
+		}
+		for i := rowSize - 1; i >= samplesPerPixel; i-- {
+			planes[i] -= planes[i-samplesPerPixel]
+		}
+	}
+	return result
+}
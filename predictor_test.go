@@ -0,0 +1,52 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestUndoHorizontalDifferencing(t *testing.T) {
+	r := rand.New(rand.NewPCG(0, 0))
+	for _, byteOrder := range []testByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, bytesPerSample := range []int{1, 2, 4, 8} {
+			for _, samplesPerPixel := range []int{1, 3} {
+				t.Run(byteOrder.String()+"_"+strconv.Itoa(bytesPerSample)+"_"+strconv.Itoa(samplesPerPixel), func(t *testing.T) {
+					width := 7
+					expected := make([]byte, 3*width*samplesPerPixel*bytesPerSample)
+					for i := range expected {
+						expected[i] = byte(r.Uint32())
+					}
+					actual := applyHorizontalDifferencing(expected, width, samplesPerPixel, bytesPerSample, byteOrder)
+					assert.NotEqual(t, expected, actual)
+					assert.NoError(t, undoHorizontalDifferencing(actual, width, samplesPerPixel, bytesPerSample, byteOrder))
+					assert.Equal(t, expected, actual)
+				})
+			}
+		}
+	}
+}
+
+func TestUndoFloatingPointPredictor(t *testing.T) {
+	r := rand.New(rand.NewPCG(0, 0))
+	for _, byteOrder := range []testByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, bytesPerSample := range []int{4, 8} {
+			for _, samplesPerPixel := range []int{1, 3} {
+				t.Run(byteOrder.String()+"_"+strconv.Itoa(bytesPerSample)+"_"+strconv.Itoa(samplesPerPixel), func(t *testing.T) {
+					width := 7
+					expected := make([]byte, 3*width*samplesPerPixel*bytesPerSample)
+					for i := range expected {
+						expected[i] = byte(r.Uint32())
+					}
+					actual := applyFloatingPointPredictor(expected, width, samplesPerPixel, bytesPerSample, byteOrder)
+					assert.NotEqual(t, expected, actual)
+					assert.NoError(t, undoFloatingPointPredictor(actual, width, samplesPerPixel, bytesPerSample, byteOrder))
+					assert.Equal(t, expected, actual)
+				})
+			}
+		}
+	}
+}
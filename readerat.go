@@ -0,0 +1,68 @@
+package elevation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// A readerAtContext is an io.ReaderAt whose reads can be canceled with a
+// context, like the files of an HTTPFS.
+type readerAtContext interface {
+	ReadAtContext(ctx context.Context, p []byte, off int64) (int, error)
+}
+
+// A readSeekerAt is an io.ReaderAt that reads from an io.ReadSeeker.
+type readSeekerAt struct {
+	mutex      sync.Mutex
+	readSeeker io.ReadSeeker
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *readSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, err := r.readSeeker.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.readSeeker, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// newFileReaderAt returns an io.ReaderAt that reads from file and file's size.
+// If file does not implement io.ReaderAt or io.ReadSeeker, for example if it
+// is a compressed file in a zip archive, then its contents are read into
+// memory.
+func newFileReaderAt(file fs.File) (io.ReaderAt, int64, error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	switch file := file.(type) {
+	case io.ReaderAt:
+		return file, fileInfo.Size(), nil
+	case io.ReadSeeker:
+		return &readSeekerAt{readSeeker: file}, fileInfo.Size(), nil
+	default:
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		return bytes.NewReader(data), int64(len(data)), nil
+	}
+}
+
+// readAtContext reads len(p) bytes from r at off, passing ctx to r if it is a
+// readerAtContext.
+func readAtContext(ctx context.Context, r io.ReaderAt, p []byte, off int64) (int, error) {
+	if r, ok := r.(readerAtContext); ok {
+		return r.ReadAtContext(ctx, p, off)
+	}
+	return r.ReadAt(p, off)
+}
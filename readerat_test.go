@@ -0,0 +1,31 @@
+package elevation
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestReadSeekerAt(t *testing.T) {
+	r := &readSeekerAt{
+		readSeeker: bytes.NewReader([]byte("0123456789")),
+	}
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "3456", string(buf))
+
+	n, err = r.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "0123", string(buf))
+
+	n, err = r.ReadAt(buf, 8)
+	assert.IsError(t, err, io.EOF)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "89", string(buf[:n]))
+}
@@ -0,0 +1,107 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// TIFF sample formats.
+const (
+	sampleFormatUint   = 1
+	sampleFormatInt    = 2
+	sampleFormatIEEEFP = 3
+)
+
+// A sampleFunc returns the ith sample in data.
+type sampleFunc func(data []byte, i int) float64
+
+// newSampleFunc returns a sampleFunc for samples with the given format and
+// number of bits, stored in byteOrder.
+func newSampleFunc(sampleFormat, bitsPerSample int, byteOrder binary.ByteOrder) (sampleFunc, error) {
+	switch {
+	case sampleFormat == sampleFormatUint && bitsPerSample == 8:
+		return func(data []byte, i int) float64 {
+			return float64(data[i])
+		}, nil
+	case sampleFormat == sampleFormatUint && bitsPerSample == 16:
+		return func(data []byte, i int) float64 {
+			return float64(byteOrder.Uint16(data[2*i:]))
+		}, nil
+	case sampleFormat == sampleFormatUint && bitsPerSample == 32:
+		return func(data []byte, i int) float64 {
+			return float64(byteOrder.Uint32(data[4*i:]))
+		}, nil
+	case sampleFormat == sampleFormatInt && bitsPerSample == 8:
+		return func(data []byte, i int) float64 {
+			return float64(int8(data[i]))
+		}, nil
+	case sampleFormat == sampleFormatInt && bitsPerSample == 16:
+		return func(data []byte, i int) float64 {
+			return float64(int16(byteOrder.Uint16(data[2*i:])))
+		}, nil
+	case sampleFormat == sampleFormatInt && bitsPerSample == 32:
+		return func(data []byte, i int) float64 {
+			return float64(int32(byteOrder.Uint32(data[4*i:])))
+		}, nil
+	case sampleFormat == sampleFormatIEEEFP && bitsPerSample == 32:
+		return func(data []byte, i int) float64 {
+			return float64(math.Float32frombits(byteOrder.Uint32(data[4*i:])))
+		}, nil
+	case sampleFormat == sampleFormatIEEEFP && bitsPerSample == 64:
+		return func(data []byte, i int) float64 {
+			return math.Float64frombits(byteOrder.Uint64(data[8*i:]))
+		}, nil
+	default:
+		return nil, fmt.Errorf("sample format %d with %d bits per sample: %w", sampleFormat, bitsPerSample, errors.ErrUnsupported)
+	}
+}
+
+// sampleBytes returns value stored as a sample with the given format and
+// number of bits in byteOrder. value must be exactly representable, see
+// sampleValue.
+func sampleBytes(value float64, sampleFormat, bitsPerSample int, byteOrder binary.ByteOrder) []byte {
+	var bits uint64
+	switch {
+	case sampleFormat == sampleFormatInt:
+		bits = uint64(int64(value))
+	case sampleFormat == sampleFormatIEEEFP && bitsPerSample == 32:
+		bits = uint64(math.Float32bits(float32(value)))
+	case sampleFormat == sampleFormatIEEEFP:
+		bits = math.Float64bits(value)
+	default:
+		bits = uint64(value)
+	}
+	data := make([]byte, bitsPerSample/8)
+	switch bitsPerSample {
+	case 8:
+		data[0] = byte(bits)
+	case 16:
+		byteOrder.PutUint16(data, uint16(bits))
+	case 32:
+		byteOrder.PutUint32(data, uint32(bits))
+	case 64:
+		byteOrder.PutUint64(data, bits)
+	}
+	return data
+}
+
+// sampleValue returns value as it would be stored in a sample with the given
+// format and number of bits, and whether it can be stored exactly.
+func sampleValue(value float64, sampleFormat, bitsPerSample int) (float64, bool) {
+	switch sampleFormat {
+	case sampleFormatUint:
+		return value, value == math.Trunc(value) && 0 <= value && value < math.Exp2(float64(bitsPerSample))
+	case sampleFormatInt:
+		limit := math.Exp2(float64(bitsPerSample - 1))
+		return value, value == math.Trunc(value) && -limit <= value && value < limit
+	case sampleFormatIEEEFP:
+		if bitsPerSample == 32 {
+			return float64(float32(value)), true
+		}
+		return value, true
+	default:
+		return value, false
+	}
+}
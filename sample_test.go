@@ -0,0 +1,58 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSampleValue(t *testing.T) {
+	for _, tc := range []struct {
+		value         float64
+		sampleFormat  int
+		bitsPerSample int
+		expected      float64
+		expectedOK    bool
+	}{
+		{value: 255, sampleFormat: sampleFormatUint, bitsPerSample: 8, expected: 255, expectedOK: true},
+		{value: 256, sampleFormat: sampleFormatUint, bitsPerSample: 8, expected: 256},
+		{value: -1, sampleFormat: sampleFormatUint, bitsPerSample: 16, expected: -1},
+		{value: -32768, sampleFormat: sampleFormatInt, bitsPerSample: 16, expected: -32768, expectedOK: true},
+		{value: 32768, sampleFormat: sampleFormatInt, bitsPerSample: 16, expected: 32768},
+		{value: -9999.5, sampleFormat: sampleFormatInt, bitsPerSample: 32, expected: -9999.5},
+		{value: 0.1, sampleFormat: sampleFormatIEEEFP, bitsPerSample: 32, expected: float64(float32(0.1)), expectedOK: true},
+		{value: 0.1, sampleFormat: sampleFormatIEEEFP, bitsPerSample: 64, expected: 0.1, expectedOK: true},
+	} {
+		actual, actualOK := sampleValue(tc.value, tc.sampleFormat, tc.bitsPerSample)
+		assert.Equal(t, tc.expected, actual)
+		assert.Equal(t, tc.expectedOK, actualOK)
+	}
+}
+
+func TestSampleBytes(t *testing.T) {
+	for _, tc := range []struct {
+		value         float64
+		sampleFormat  int
+		bitsPerSample int
+	}{
+		{value: 255, sampleFormat: sampleFormatUint, bitsPerSample: 8},
+		{value: 65535, sampleFormat: sampleFormatUint, bitsPerSample: 16},
+		{value: 4294967295, sampleFormat: sampleFormatUint, bitsPerSample: 32},
+		{value: -128, sampleFormat: sampleFormatInt, bitsPerSample: 8},
+		{value: -32768, sampleFormat: sampleFormatInt, bitsPerSample: 16},
+		{value: -9999, sampleFormat: sampleFormatInt, bitsPerSample: 32},
+		{value: -math.MaxFloat32, sampleFormat: sampleFormatIEEEFP, bitsPerSample: 32},
+		{value: math.NaN(), sampleFormat: sampleFormatIEEEFP, bitsPerSample: 32},
+		{value: 0.1, sampleFormat: sampleFormatIEEEFP, bitsPerSample: 64},
+	} {
+		for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			sampleFunc, err := newSampleFunc(tc.sampleFormat, tc.bitsPerSample, byteOrder)
+			assert.NoError(t, err)
+			data := sampleBytes(tc.value, tc.sampleFormat, tc.bitsPerSample, byteOrder)
+			assert.Equal(t, tc.bitsPerSample/8, len(data))
+			assert.Equal(t, tc.value, sampleFunc(data, 0))
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package elevation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// TIFF field types.
+const (
+	tiffTypeASCII  = 2
+	tiffTypeShort  = 3
+	tiffTypeLong   = 4
+	tiffTypeDouble = 12
+	tiffTypeLong8  = 16
+)
+
+// TIFF tags written by encodeTIFF.
+const (
+	tiffTagNewSubfileType            = 254
+	tiffTagImageWidth                = 256
+	tiffTagImageLength               = 257
+	tiffTagBitsPerSample             = 258
+	tiffTagCompression               = 259
+	tiffTagPhotometricInterpretation = 262
+	tiffTagSamplesPerPixel           = 277
+	tiffTagPlanarConfiguration       = 284
+	tiffTagPredictor                 = 317
+	tiffTagTileWidth                 = 322
+	tiffTagTileLength                = 323
+	tiffTagTileOffsets               = 324
+	tiffTagTileByteCounts            = 325
+	tiffTagSampleFormat              = 339
+	tiffTagModelPixelScale           = 33550
+	tiffTagModelTiepoint             = 33922
+	tiffTagGeoKeyDirectory           = 34735
+	tiffTagGDALNoData                = 42113
+)
+
+// A tiffField is a TIFF field to be written. Its value is a []uint16,
+// []uint32, []float64, or string. []uint64 values are written as LONGs in
+// TIFF files and as LONG8s in BigTIFF files.
+type tiffField struct {
+	tag   uint16
+	value any
+}
+
+// A tiffImage is a tiled TIFF image to be written.
+type tiffImage struct {
+	fields []tiffField // Fields, excluding tile offsets and byte counts.
+	tiles  [][]byte    // Compressed tiles.
+}
+
+// A tiffEncodedField is a tiffField encoded for writing.
+type tiffEncodedField struct {
+	tag       uint16
+	fieldType uint16
+	count     int
+	data      []byte
+	offset    uint64 // Offset of data, if it is not stored in the IFD entry.
+}
+
+// encodeTIFF writes images to w as a little-endian TIFF file, or BigTIFF file
+// if bigTIFF is true. ghostArea, if any, is written immediately after the
+// header, where it is ignored by TIFF readers. All IFDs are written next,
+// followed by the tile data of the images in reverse order, so that the tile
+// data of any overviews comes before the tile data of the full resolution
+// image.
+func encodeTIFF(w io.Writer, images []*tiffImage, bigTIFF bool, ghostArea []byte) error {
+	byteOrder := binary.LittleEndian
+	headerSize, countSize, entrySize, offsetSize, inlineSize := uint64(8), uint64(2), uint64(12), uint64(4), 4
+	if bigTIFF {
+		headerSize, countSize, entrySize, offsetSize, inlineSize = 16, 8, 20, 8, 8
+	}
+
+	// Add tile offsets and byte counts, which are set below.
+	imagesFields := make([][]tiffField, len(images))
+	for i, image := range images {
+		imagesFields[i] = append(slices.Clone(image.fields),
+			tiffField{tag: tiffTagTileOffsets, value: make([]uint64, len(image.tiles))},
+			tiffField{tag: tiffTagTileByteCounts, value: make([]uint64, len(image.tiles))},
+		)
+		slices.SortFunc(imagesFields[i], func(a, b tiffField) int {
+			return int(a.tag) - int(b.tag)
+		})
+	}
+
+	// Lay out the IFDs, each followed by its field data that does not fit in
+	// its entries, and then the tile data.
+	ifdOffsets := make([]uint64, len(images))
+	offset := headerSize + uint64(len(ghostArea)+len(ghostArea)%2)
+	for i, fields := range imagesFields {
+		ifdOffsets[i] = offset
+		offset += countSize + uint64(len(fields))*entrySize + offsetSize
+		for _, field := range fields {
+			if n := tiffFieldDataSize(field, bigTIFF); n > inlineSize {
+				offset += uint64(n + n%2)
+			}
+		}
+	}
+	for i := len(images) - 1; i >= 0; i-- {
+		var tileOffsets, tileByteCounts []uint64
+		for _, field := range imagesFields[i] {
+			switch field.tag {
+			case tiffTagTileOffsets:
+				tileOffsets = field.value.([]uint64)
+			case tiffTagTileByteCounts:
+				tileByteCounts = field.value.([]uint64)
+			}
+		}
+		for j, tile := range images[i].tiles {
+			tileOffsets[j] = offset
+			tileByteCounts[j] = uint64(len(tile))
+			offset += uint64(len(tile))
+		}
+	}
+	if !bigTIFF && offset > math.MaxUint32 {
+		return fmt.Errorf("%d bytes: too large for TIFF, use BigTIFF", offset)
+	}
+
+	bw := bufio.NewWriter(w)
+	var buffer []byte
+	if bigTIFF {
+		buffer = append(buffer, 'I', 'I')
+		buffer = byteOrder.AppendUint16(buffer, 43)
+		buffer = byteOrder.AppendUint16(buffer, 8)
+		buffer = byteOrder.AppendUint16(buffer, 0)
+		buffer = byteOrder.AppendUint64(buffer, ifdOffsets[0])
+	} else {
+		buffer = append(buffer, 'I', 'I')
+		buffer = byteOrder.AppendUint16(buffer, 42)
+		buffer = byteOrder.AppendUint32(buffer, uint32(ifdOffsets[0]))
+	}
+	buffer = append(buffer, ghostArea...)
+	if len(ghostArea)%2 != 0 {
+		buffer = append(buffer, 0)
+	}
+	appendOffset := func(buffer []byte, value uint64) []byte {
+		if bigTIFF {
+			return byteOrder.AppendUint64(buffer, value)
+		}
+		return byteOrder.AppendUint32(buffer, uint32(value))
+	}
+
+	for i, fields := range imagesFields {
+		encodedFields := make([]tiffEncodedField, len(fields))
+		dataOffset := ifdOffsets[i] + countSize + uint64(len(fields))*entrySize + offsetSize
+		for j, field := range fields {
+			encodedField := encodeTIFFField(field, bigTIFF)
+			if len(encodedField.data) > inlineSize {
+				encodedField.offset = dataOffset
+				dataOffset += uint64(len(encodedField.data) + len(encodedField.data)%2)
+			}
+			encodedFields[j] = encodedField
+		}
+
+		if bigTIFF {
+			buffer = byteOrder.AppendUint64(buffer, uint64(len(fields)))
+		} else {
+			buffer = byteOrder.AppendUint16(buffer, uint16(len(fields)))
+		}
+		for _, encodedField := range encodedFields {
+			buffer = byteOrder.AppendUint16(buffer, encodedField.tag)
+			buffer = byteOrder.AppendUint16(buffer, encodedField.fieldType)
+			buffer = appendOffset(buffer, uint64(encodedField.count))
+			if len(encodedField.data) > inlineSize {
+				buffer = appendOffset(buffer, encodedField.offset)
+			} else {
+				buffer = append(buffer, encodedField.data...)
+				buffer = append(buffer, make([]byte, inlineSize-len(encodedField.data))...)
+			}
+		}
+		var nextIFDOffset uint64
+		if i+1 < len(images) {
+			nextIFDOffset = ifdOffsets[i+1]
+		}
+		buffer = appendOffset(buffer, nextIFDOffset)
+		for _, encodedField := range encodedFields {
+			if len(encodedField.data) > inlineSize {
+				buffer = append(buffer, encodedField.data...)
+				if len(encodedField.data)%2 != 0 {
+					buffer = append(buffer, 0)
+				}
+			}
+		}
+		if _, err := bw.Write(buffer); err != nil {
+			return err
+		}
+		buffer = buffer[:0]
+	}
+
+	for i := len(images) - 1; i >= 0; i-- {
+		for _, tile := range images[i].tiles {
+			if _, err := bw.Write(tile); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// encodeTIFFField returns field encoded for writing.
+func encodeTIFFField(field tiffField, bigTIFF bool) tiffEncodedField {
+	byteOrder := binary.LittleEndian
+	encodedField := tiffEncodedField{
+		tag: field.tag,
+	}
+	switch value := field.value.(type) {
+	case []uint16:
+		encodedField.fieldType = tiffTypeShort
+		encodedField.count = len(value)
+		for _, v := range value {
+			encodedField.data = byteOrder.AppendUint16(encodedField.data, v)
+		}
+	case []uint32:
+		encodedField.fieldType = tiffTypeLong
+		encodedField.count = len(value)
+		for _, v := range value {
+			encodedField.data = byteOrder.AppendUint32(encodedField.data, v)
+		}
+	case []uint64:
+		encodedField.count = len(value)
+		if bigTIFF {
+			encodedField.fieldType = tiffTypeLong8
+			for _, v := range value {
+				encodedField.data = byteOrder.AppendUint64(encodedField.data, v)
+			}
+		} else {
+			encodedField.fieldType = tiffTypeLong
+			for _, v := range value {
+				encodedField.data = byteOrder.AppendUint32(encodedField.data, uint32(v))
+			}
+		}
+	case []float64:
+		encodedField.fieldType = tiffTypeDouble
+		encodedField.count = len(value)
+		for _, v := range value {
+			encodedField.data = byteOrder.AppendUint64(encodedField.data, math.Float64bits(v))
+		}
+	case string:
+		encodedField.fieldType = tiffTypeASCII
+		encodedField.count = len(value) + 1
+		encodedField.data = append([]byte(value), 0)
+	default:
+		panic(fmt.Sprintf("%T: unsupported TIFF field value type", value))
+	}
+	return encodedField
+}
+
+// tiffFieldDataSize returns the size of field's encoded data.
+func tiffFieldDataSize(field tiffField, bigTIFF bool) int {
+	switch value := field.value.(type) {
+	case []uint16:
+		return 2 * len(value)
+	case []uint32:
+		return 4 * len(value)
+	case []uint64:
+		if bigTIFF {
+			return 8 * len(value)
+		}
+		return 4 * len(value)
+	case []float64:
+		return 8 * len(value)
+	case string:
+		return len(value) + 1
+	default:
+		panic(fmt.Sprintf("%T: unsupported TIFF field value type", value))
+	}
+}